@@ -0,0 +1,170 @@
+// Package api provides the JSON API for scripts and other non-interactive clients.
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bouncepaw/mycorrhiza/internal/cfg"
+	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/mimetype"
+	"github.com/bouncepaw/mycorrhiza/internal/shroom"
+	"github.com/bouncepaw/mycorrhiza/internal/user"
+	"github.com/bouncepaw/mycorrhiza/util"
+
+	"github.com/gorilla/mux"
+)
+
+// InitHandlers registers the handlers of the API version 1 under /api/v1. The wiki lock is checked here, because the API cannot redirect to the lock page.
+func InitHandlers(rtr *mux.Router) {
+	r := rtr.PathPrefix("/api/v1").Subrouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+			if cfg.Locked && user.FromRequest(rq).Group == "anon" {
+				writeError(w, http.StatusUnauthorized, "The wiki is locked, log in to use it")
+				return
+			}
+			next.ServeHTTP(w, rq)
+		})
+	})
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		writeError(w, http.StatusNotFound, "No such API endpoint")
+	})
+
+	// Methods are dispatched here and not by the router, because the router answers 404 instead of 405 to wrong methods in subrouters.
+	r.HandleFunc("/hyphae", byMethod(map[string]http.HandlerFunc{
+		http.MethodGet: handlerList,
+	}))
+	r.PathPrefix("/hyphae/").HandlerFunc(byMethod(map[string]http.HandlerFunc{
+		http.MethodGet:    handlerGetHypha,
+		http.MethodPut:    handlerPutHypha,
+		http.MethodDelete: handlerDeleteHypha,
+	}))
+	r.PathPrefix("/rename/").HandlerFunc(byMethod(map[string]http.HandlerFunc{
+		http.MethodPost: handlerRename,
+	}))
+	r.PathPrefix("/media/").HandlerFunc(byMethod(map[string]http.HandlerFunc{
+		http.MethodPut:    handlerPutMedia,
+		http.MethodPost:   handlerPutMedia,
+		http.MethodDelete: handlerDeleteMedia,
+	}))
+	r.PathPrefix("/leases/").HandlerFunc(byMethod(map[string]http.HandlerFunc{
+		http.MethodGet:    handlerGetLeases,
+		http.MethodPost:   handlerTakeLease,
+		http.MethodDelete: handlerReleaseLease,
+	}))
+}
+
+func byMethod(handlers map[string]http.HandlerFunc) http.HandlerFunc {
+	var allowed []string
+	for method := range handlers {
+		allowed = append(allowed, method)
+	}
+	sort.Strings(allowed)
+	return func(w http.ResponseWriter, rq *http.Request) {
+		handler, ok := handlers[rq.Method]
+		if !ok {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed, allowed methods: "+strings.Join(allowed, ", "))
+			return
+		}
+		handler(w, rq)
+	}
+}
+
+// Hypha is how a hypha looks in the API.
+type Hypha struct {
+	Name   string `json:"name"`
+	Title  string `json:"title"`
+	Exists bool   `json:"exists"`
+	// Format is markdown or mycomarkup. It is set if the hypha has text.
+	Format string `json:"format,omitempty"`
+	// Text and Revision are only sent when a single hypha is requested. Send the revision back with the edit to make sure nobody changed the text meanwhile.
+	Text     *string `json:"text,omitempty"`
+	Revision string  `json:"revision,omitempty"`
+	Media    *Media  `json:"media,omitempty"`
+}
+
+// Media is the media part of a hypha.
+type Media struct {
+	URL  string `json:"url"`
+	MIME string `json:"mime"`
+}
+
+// Error is the body of every unsuccessful response.
+type Error struct {
+	Error ErrorDetails `json:"error"`
+	// Conflict is only set for edits rejected because of an edit conflict.
+	Conflict *Conflict `json:"conflict,omitempty"`
+}
+
+// ErrorDetails tells what went wrong. Code is the HTTP status code.
+type ErrorDetails struct {
+	Code    int    `json:"code"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// Conflict tells how the text of the hypha changed since the base revision of the rejected edit.
+type Conflict struct {
+	CurrentRevision string `json:"current_revision"`
+	CurrentText     string `json:"current_text"`
+	// MergedText is the edit merged with the current text. Conflicts is the number of places in it where they could not be merged, marked like git does.
+	MergedText string `json:"merged_text"`
+	Conflicts  int    `json:"conflicts"`
+}
+
+func hyphaFrom(h hyphae.Hypha, withText bool) Hypha {
+	result := Hypha{
+		Name:  h.CanonicalName(),
+		Title: util.BeautifulName(h.CanonicalName()),
+	}
+	existing, ok := h.(hyphae.ExistingHypha)
+	if !ok {
+		return result
+	}
+	result.Exists = true
+	if existing.HasTextFile() {
+		switch hyphae.DetectTextFormat(existing.TextFilePath()) {
+		case hyphae.FormatMarkdown:
+			result.Format = "markdown"
+		default:
+			result.Format = "mycomarkup"
+		}
+	}
+	if withText {
+		text, err := hyphae.FetchMycomarkupFile(h)
+		if err != nil {
+			slog.Error("Failed to fetch text of hypha", "hyphaName", h.CanonicalName(), "err", err)
+		}
+		result.Text = &text
+		result.Revision = shroom.BaseRevision(h)
+	}
+	if media, ok := h.(*hyphae.MediaHypha); ok {
+		result.Media = &Media{
+			URL:  "/binary/" + h.CanonicalName(),
+			MIME: mimetype.FromExtension(filepath.Ext(media.MediaFilePath())),
+		}
+	}
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		slog.Error("Failed to write API response", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, Error{Error: ErrorDetails{
+		Code:    status,
+		Status:  http.StatusText(status),
+		Message: message,
+	}})
+}
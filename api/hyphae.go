@@ -0,0 +1,256 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/leases"
+	"github.com/bouncepaw/mycorrhiza/internal/shroom"
+	"github.com/bouncepaw/mycorrhiza/internal/user"
+	"github.com/bouncepaw/mycorrhiza/l18n"
+	"github.com/bouncepaw/mycorrhiza/util"
+)
+
+// handlerList lists hyphae. With the q parameter, they are found like in the title search and sorted by relevance, otherwise they are sorted by name. The prefix parameter leaves only the hyphae whose names start with it, and limit caps their number.
+func handlerList(w http.ResponseWriter, rq *http.Request) {
+	if !user.CanProceed(rq, "text") {
+		writeError(w, http.StatusForbidden, "No rights to read hyphae")
+		return
+	}
+	var (
+		prefix    = util.CanonicalName(rq.FormValue("prefix"))
+		limit, _  = strconv.Atoi(rq.FormValue("limit"))
+		hyphaList = []Hypha{}
+	)
+	for _, hyphaName := range shroom.HyphaNamesMatching(rq.FormValue("q"), 0) {
+		if !strings.HasPrefix(hyphaName, prefix) {
+			continue
+		}
+		if limit > 0 && len(hyphaList) == limit {
+			break
+		}
+		hyphaList = append(hyphaList, hyphaFrom(hyphae.ByName(hyphaName), false))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"hyphae": hyphaList})
+}
+
+// handlerGetHypha returns the hypha with its text.
+func handlerGetHypha(w http.ResponseWriter, rq *http.Request) {
+	if !user.CanProceed(rq, "text") {
+		writeError(w, http.StatusForbidden, "No rights to read hyphae")
+		return
+	}
+	h := hyphae.ByName(util.HyphaNameFromRq(rq, "api/v1/hyphae"))
+	if _, ok := h.(*hyphae.EmptyHypha); ok {
+		writeError(w, http.StatusNotFound, "The hypha does not exist")
+		return
+	}
+	writeJSON(w, http.StatusOK, hyphaFrom(h, true))
+}
+
+// textUpload is the body of PUT /api/v1/hyphae/{hypha}.
+type textUpload struct {
+	Text    string `json:"text"`
+	Message string `json:"message"`
+	// Format is markdown or mycomarkup, the latter by default. It is only used for new hyphae, existing hyphae keep their format.
+	Format string `json:"format"`
+	// BaseRevision is the revision of the hypha the edit is based on, none for new hyphae. If someone else changed the text since then, the edit is rejected. Without it, the text is overwritten anyway.
+	BaseRevision string `json:"base_revision"`
+	// Lease is the ID of the lease to release after saving. Only anonymous users need it, see POST /api/v1/leases.
+	Lease string `json:"lease"`
+}
+
+// handlerPutHypha creates the hypha or replaces its text.
+func handlerPutHypha(w http.ResponseWriter, rq *http.Request) {
+	var (
+		u          = user.FromRequest(rq)
+		lc         = l18n.FromRequest(rq)
+		h          = hyphae.ByName(util.HyphaNameFromRq(rq, "api/v1/hyphae"))
+		_, isNew   = h.(*hyphae.EmptyHypha)
+		upload     textUpload
+		textFormat = hyphae.FormatMycomarkup
+	)
+	if err := shroom.CanEdit(u, h, lc); err != nil {
+		writeError(w, http.StatusForbidden, lc.Get(err.Error()))
+		return
+	}
+	if err := json.NewDecoder(rq.Body).Decode(&upload); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+
+	switch {
+	case !isNew:
+		textFormat = hyphae.DetectTextFormat(h.(hyphae.ExistingHypha).TextFilePath())
+	case upload.Format == "markdown":
+		textFormat = hyphae.FormatMarkdown
+	case upload.Format != "" && upload.Format != "mycomarkup":
+		writeError(w, http.StatusBadRequest, "Unknown format, use markdown or mycomarkup")
+		return
+	}
+
+	err := shroom.UploadText(h, []byte(upload.Text), upload.Message, u, textFormat, upload.BaseRevision)
+	var conflict *shroom.EditConflictError
+	if errors.As(err, &conflict) {
+		writeJSON(w, http.StatusConflict, Error{
+			Error: ErrorDetails{
+				Code:    http.StatusConflict,
+				Status:  http.StatusText(http.StatusConflict),
+				Message: conflict.Error(),
+			},
+			Conflict: &Conflict{
+				CurrentRevision: conflict.CurrentRevision,
+				CurrentText:     conflict.Current,
+				MergedText:      conflict.Merged,
+				Conflicts:       conflict.Conflicts,
+			},
+		})
+		return
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if !hyphae.IsValidName(h.CanonicalName()) {
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, lc.Get(err.Error()))
+		return
+	}
+	leases.Release(h.CanonicalName(), u.Name, upload.Lease)
+
+	h = hyphae.ByName(h.CanonicalName())
+	if _, created := h.(hyphae.ExistingHypha); isNew && created {
+		writeJSON(w, http.StatusCreated, hyphaFrom(h, true))
+		return
+	}
+	writeJSON(w, http.StatusOK, hyphaFrom(h, true))
+}
+
+// handlerDeleteHypha deletes the hypha with all its parts.
+func handlerDeleteHypha(w http.ResponseWriter, rq *http.Request) {
+	var (
+		u = user.FromRequest(rq)
+		h = hyphae.ByName(util.HyphaNameFromRq(rq, "api/v1/hyphae"))
+	)
+	if !u.CanProceed("delete") {
+		slog.Info("No rights to delete hypha", "username", u.Name, "hyphaName", h.CanonicalName())
+		writeError(w, http.StatusForbidden, "No rights to delete hyphae")
+		return
+	}
+	existing, ok := h.(hyphae.ExistingHypha)
+	if !ok {
+		writeError(w, http.StatusNotFound, "The hypha does not exist")
+		return
+	}
+	if err := shroom.Delete(u, existing); err != nil {
+		slog.Error("Failed to delete hypha", "hyphaName", h.CanonicalName(), "err", err)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// renaming is the body of POST /api/v1/rename/{hypha}. The booleans mean the same as the checkboxes of the rename form.
+type renaming struct {
+	NewName      string `json:"new_name"`
+	Recursive    bool   `json:"recursive"`
+	Redirections bool   `json:"redirections"`
+	UpdateLinks  bool   `json:"update_links"`
+}
+
+// handlerRename renames the hypha and returns it under the new name.
+func handlerRename(w http.ResponseWriter, rq *http.Request) {
+	var (
+		u       = user.FromRequest(rq)
+		lc      = l18n.FromRequest(rq)
+		h       = hyphae.ByName(util.HyphaNameFromRq(rq, "api/v1/rename"))
+		request renaming
+	)
+	if !u.CanProceed("rename") {
+		slog.Info("No rights to rename hypha", "username", u.Name, "hyphaName", h.CanonicalName())
+		writeError(w, http.StatusForbidden, "No rights to rename hyphae")
+		return
+	}
+	existing, ok := h.(hyphae.ExistingHypha)
+	if !ok {
+		writeError(w, http.StatusNotFound, "The hypha does not exist")
+		return
+	}
+	if err := json.NewDecoder(rq.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON: "+err.Error())
+		return
+	}
+
+	newName := util.CanonicalName(request.NewName)
+	if err := shroom.Rename(existing, newName, request.Recursive, request.Redirections, request.UpdateLinks, u); err != nil {
+		slog.Error("Failed to rename hypha", "username", u.Name, "hyphaName", h.CanonicalName(), "err", err)
+		var status int
+		switch err.Error() {
+		case "ui.rename_noname_tip", "ui.rename_badname_tip":
+			status = http.StatusBadRequest
+		case "ui.rename_taken_tip":
+			status = http.StatusConflict
+		default:
+			status = http.StatusInternalServerError
+		}
+		writeError(w, status, lc.Get(err.Error(), &l18n.Replacements{"name": newName}))
+		return
+	}
+	writeJSON(w, http.StatusOK, hyphaFrom(hyphae.ByName(newName), false))
+}
+
+// handlerPutMedia uploads new media for the hypha. The file is sent in the binary field of a multipart form, like in the upload form.
+func handlerPutMedia(w http.ResponseWriter, rq *http.Request) {
+	var (
+		u  = user.FromRequest(rq)
+		lc = l18n.FromRequest(rq)
+		h  = hyphae.ByName(util.HyphaNameFromRq(rq, "api/v1/media"))
+	)
+	if err := shroom.CanAttach(u, h, lc); err != nil {
+		writeError(w, http.StatusForbidden, lc.Get(err.Error()))
+		return
+	}
+	if err := rq.ParseMultipartForm(10 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid multipart form: "+err.Error())
+		return
+	}
+	file, header, err := rq.FormFile("binary")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "No file in the binary field")
+		return
+	}
+	defer file.Close()
+
+	if err := shroom.UploadBinary(h, header.Header.Get("Content-Type"), file, u); err != nil {
+		slog.Error("Failed to upload media", "hyphaName", h.CanonicalName(), "err", err)
+		writeError(w, http.StatusInternalServerError, lc.Get(err.Error()))
+		return
+	}
+	writeJSON(w, http.StatusOK, hyphaFrom(hyphae.ByName(h.CanonicalName()), false))
+}
+
+// handlerDeleteMedia removes the media of the hypha. If the hypha had no text, it is deleted.
+func handlerDeleteMedia(w http.ResponseWriter, rq *http.Request) {
+	var (
+		u = user.FromRequest(rq)
+		h = hyphae.ByName(util.HyphaNameFromRq(rq, "api/v1/media"))
+	)
+	if !u.CanProceed("remove-media") {
+		writeError(w, http.StatusForbidden, "No rights to remove media")
+		return
+	}
+	media, ok := h.(*hyphae.MediaHypha)
+	if !ok {
+		writeError(w, http.StatusNotFound, "The hypha has no media")
+		return
+	}
+	if err := shroom.RemoveMedia(u, media); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, hyphaFrom(hyphae.ByName(h.CanonicalName()), false))
+}
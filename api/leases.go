@@ -0,0 +1,107 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/leases"
+	"github.com/bouncepaw/mycorrhiza/internal/shroom"
+	"github.com/bouncepaw/mycorrhiza/internal/user"
+	"github.com/bouncepaw/mycorrhiza/l18n"
+	"github.com/bouncepaw/mycorrhiza/util"
+)
+
+// Lease is how an edit lease looks in the API.
+type Lease struct {
+	// ID is only shown to the holder of the lease. Anonymous users need it to renew and release the lease.
+	ID        string    `json:"id,omitempty"`
+	HyphaName string    `json:"hypha"`
+	Username  string    `json:"user"`
+	Since     time.Time `json:"since"`
+	Until     time.Time `json:"until"`
+}
+
+// LeaseTaken is the body of the response to a lease request refused because someone else is editing the hypha.
+type LeaseTaken struct {
+	Error  ErrorDetails `json:"error"`
+	Leases []Lease      `json:"leases"`
+}
+
+// leaseFrom converts the lease without its ID, because the ID lets anyone release an anonymous lease.
+func leaseFrom(lease leases.Lease) Lease {
+	return Lease{
+		HyphaName: lease.HyphaName,
+		Username:  lease.Username,
+		Since:     lease.Since,
+		Until:     lease.Until,
+	}
+}
+
+func leasesFrom(ls []leases.Lease) []Lease {
+	result := make([]Lease, 0, len(ls))
+	for _, lease := range ls {
+		result = append(result, leaseFrom(lease))
+	}
+	return result
+}
+
+// handlerGetLeases lists who is editing the hypha. Only those who can edit may know that.
+func handlerGetLeases(w http.ResponseWriter, rq *http.Request) {
+	var (
+		u         = user.FromRequest(rq)
+		hyphaName = util.HyphaNameFromRq(rq, "api/v1/leases")
+	)
+	if !u.CanProceed("edit") {
+		writeError(w, http.StatusForbidden, "No rights to see who is editing hyphae")
+		return
+	}
+	writeJSON(w, http.StatusOK, leasesFrom(leases.Of(hyphaName)))
+}
+
+// handlerTakeLease takes or renews the user's lease on the hypha. Unlike opening the editor, it fails if someone else is editing the hypha.
+func handlerTakeLease(w http.ResponseWriter, rq *http.Request) {
+	var (
+		u  = user.FromRequest(rq)
+		lc = l18n.FromRequest(rq)
+		h  = hyphae.ByName(util.HyphaNameFromRq(rq, "api/v1/leases"))
+	)
+	if err := shroom.CanEdit(u, h, lc); err != nil {
+		writeError(w, http.StatusForbidden, lc.Get(err.Error()))
+		return
+	}
+	lease, others, err := leases.TryTake(h.CanonicalName(), u.Name, rq.FormValue("id"))
+	if errors.Is(err, leases.ErrTaken) {
+		writeJSON(w, http.StatusConflict, LeaseTaken{
+			Error: ErrorDetails{
+				Code:    http.StatusConflict,
+				Status:  http.StatusText(http.StatusConflict),
+				Message: "The hypha is being edited by someone else",
+			},
+			Leases: leasesFrom(others),
+		})
+		return
+	}
+	result := leaseFrom(lease)
+	result.ID = lease.ID
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handlerReleaseLease releases the user's lease on the hypha. Anonymous users share a name, so they have to give the ID of their lease.
+func handlerReleaseLease(w http.ResponseWriter, rq *http.Request) {
+	var (
+		u         = user.FromRequest(rq)
+		hyphaName = util.HyphaNameFromRq(rq, "api/v1/leases")
+		id        = rq.FormValue("id")
+	)
+	if u.Group == "anon" && id == "" {
+		writeError(w, http.StatusForbidden, "Anonymous users have to give the id of their lease")
+		return
+	}
+	if !leases.Release(hyphaName, u.Name, id) {
+		writeError(w, http.StatusNotFound, "You have no lease on the hypha")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,20 +1,109 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/bouncepaw/mycorrhiza/history"
+	"github.com/bouncepaw/mycorrhiza/internal/categories"
 	"github.com/bouncepaw/mycorrhiza/internal/converter"
+	"github.com/bouncepaw/mycorrhiza/internal/diff"
 	"github.com/bouncepaw/mycorrhiza/internal/files"
 	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/util"
 )
 
-// convertFormatCommand converts all hyphae to the specified format
-func convertFormatCommand(targetFormat string) error {
+// conversionFilter selects which hyphae -convert-format converts. The zero value selects all hyphae.
+type conversionFilter struct {
+	prefix   string // The hypha that heads the converted subtree, without a trailing slash
+	category string
+	exclude  []string // Hypha names or path.Match globs
+	// inCategory is filled from category when the categories are loaded.
+	inCategory map[string]bool
+}
+
+// newConversionFilter makes a filter from the CLI options. exclude is a comma-separated list.
+func newConversionFilter(prefix, category, exclude string) conversionFilter {
+	filter := conversionFilter{
+		prefix:   strings.TrimSuffix(util.CanonicalName(prefix), "/"),
+		category: util.CanonicalName(category),
+	}
+	for _, pattern := range strings.Split(exclude, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			// Not CanonicalName, because it would eat the glob characters.
+			filter.exclude = append(filter.exclude, strings.ToLower(strings.ReplaceAll(pattern, " ", "_")))
+		}
+	}
+	return filter
+}
+
+// load prepares the filter for use. Call after the hyphae are indexed.
+func (f *conversionFilter) load() error {
+	if f.category == "" {
+		return nil
+	}
+	if err := categories.Init(); err != nil {
+		return err
+	}
+	f.inCategory = make(map[string]bool)
+	for _, name := range categories.HyphaeInCategory(f.category) {
+		f.inCategory[name] = true
+	}
+	if len(f.inCategory) == 0 {
+		return fmt.Errorf("category %s is empty or does not exist", f.category)
+	}
+	return nil
+}
+
+func (f *conversionFilter) matches(hyphaName string) bool {
+	if f.prefix != "" && hyphaName != f.prefix && !strings.HasPrefix(hyphaName, f.prefix+"/") {
+		return false
+	}
+	if f.inCategory != nil && !f.inCategory[hyphaName] {
+		return false
+	}
+	for _, pattern := range f.exclude {
+		if matched, _ := path.Match(pattern, hyphaName); matched || pattern == hyphaName {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes the filter for commit messages. It is empty for the zero filter.
+func (f *conversionFilter) String() string {
+	var parts []string
+	if f.prefix != "" {
+		parts = append(parts, "prefix "+f.prefix+"/")
+	}
+	if f.category != "" {
+		parts = append(parts, "category "+f.category)
+	}
+	if len(f.exclude) > 0 {
+		parts = append(parts, "excluding "+strings.Join(f.exclude, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// hyphaeToConvert yields text hyphae selected by the filter that are not in the target format yet.
+func hyphaeToConvert(toFormat hyphae.TextFormat, filter conversionFilter) []hyphae.ExistingHypha {
+	var result []hyphae.ExistingHypha
+	for h := range hyphae.FilterHyphaeWithText(hyphae.YieldExistingHyphae()) {
+		if hyphae.DetectTextFormat(h.TextFilePath()) != toFormat && filter.matches(h.CanonicalName()) {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+// convertFormatCommand converts hyphae selected by the filter to the specified format. With dryRun, nothing is written and a JSON report is printed instead.
+func convertFormatCommand(targetFormat string, dryRun bool, filter conversionFilter) error {
 	if err := files.PrepareWikiRoot(); err != nil {
 		slog.Error("Failed to prepare wiki root", "err", err)
 		return err
@@ -27,14 +116,20 @@ func convertFormatCommand(targetFormat string) error {
 	}
 
 	// Initialize git
-	if err := history.Start(); err != nil {
-		return err
+	if !dryRun {
+		if err := history.Start(); err != nil {
+			return err
+		}
+		history.InitGitRepo()
 	}
-	history.InitGitRepo()
 
 	// Index all hyphae
 	slog.Info("Indexing hyphae...")
 	hyphae.Index(files.HyphaeDir())
+	if err := filter.load(); err != nil {
+		slog.Error("Failed to select hyphae to convert", "err", err)
+		return err
+	}
 
 	// Parse target format
 	var toFormat hyphae.TextFormat
@@ -47,21 +142,24 @@ func convertFormatCommand(targetFormat string) error {
 		return fmt.Errorf("unknown format: %s (use 'markdown' or 'mycomarkup')", targetFormat)
 	}
 
-	// Count hyphae that will be converted
-	var needsConversion int
-	for h := range hyphae.FilterHyphaeWithText(hyphae.YieldExistingHyphae()) {
-		if hyphae.DetectTextFormat(h.TextFilePath()) != toFormat {
-			needsConversion++
-		}
+	selectedHyphae := hyphaeToConvert(toFormat, filter)
+	if dryRun {
+		return convertDryRun(selectedHyphae, toFormat)
 	}
 
+	// Count hyphae that will be converted
+	needsConversion := len(selectedHyphae)
 	if needsConversion == 0 {
-		slog.Info("All hyphae are already in the target format", "format", hyphae.FormatName(toFormat))
+		slog.Info("All selected hyphae are already in the target format",
+			"format", hyphae.FormatName(toFormat), "filter", filter.String())
 		return nil
 	}
 
 	// Confirm conversion
 	fmt.Printf("\nWARNING: This will convert %d hyphae to %s format.\n", needsConversion, hyphae.FormatName(toFormat))
+	if filter.String() != "" {
+		fmt.Printf("Only hyphae matching %s are converted.\n", filter.String())
+	}
 	fmt.Printf("This operation will modify files in your wiki directory.\n")
 	fmt.Printf("It is STRONGLY recommended to backup your wiki before proceeding.\n\n")
 	fmt.Printf("Continue? (yes/no): ")
@@ -75,9 +173,6 @@ func convertFormatCommand(targetFormat string) error {
 
 	slog.Info("Starting format conversion", "targetFormat", hyphae.FormatName(toFormat))
 
-	// Get all hyphae with text
-	allHyphae := hyphae.FilterHyphaeWithText(hyphae.YieldExistingHyphae())
-
 	var (
 		converted     int
 		skipped       int
@@ -86,7 +181,7 @@ func convertFormatCommand(targetFormat string) error {
 		modifiedFiles []string                   // files that were modified in place
 	)
 
-	for h := range allHyphae {
+	for _, h := range selectedHyphae {
 		oldPath := h.TextFilePath()
 		fromFormat := hyphae.DetectTextFormat(oldPath)
 
@@ -187,6 +282,9 @@ func convertFormatCommand(targetFormat string) error {
 	if converted > 0 {
 		slog.Info("Committing changes to git repository...")
 		commitMsg := fmt.Sprintf("Convert %d hyphae to %s format", converted, hyphae.FormatName(toFormat))
+		if filter.String() != "" {
+			commitMsg += " (" + filter.String() + ")"
+		}
 
 		hop := history.Operation(history.TypeMarkupMigration).
 			WithMsg(commitMsg)
@@ -223,6 +321,83 @@ func convertFormatCommand(targetFormat string) error {
 	return nil
 }
 
+// conversionReport is what -convert-dry-run prints.
+type conversionReport struct {
+	TargetFormat string                  `json:"target_format"`
+	Hyphae       []hyphaConversionReport `json:"hyphae"`
+}
+
+// hyphaConversionReport describes the would-be conversion of one hypha.
+type hyphaConversionReport struct {
+	Hypha      string   `json:"hypha"`
+	FromFormat string   `json:"from_format"`
+	ToFormat   string   `json:"to_format"`
+	Path       string   `json:"path"`
+	NewPath    string   `json:"new_path"`
+	Warnings   []string `json:"warnings"`
+	Diff       string   `json:"diff"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// convertDryRun converts the hyphae in memory and prints a JSON report about that to stdout. Nothing is written.
+func convertDryRun(selectedHyphae []hyphae.ExistingHypha, toFormat hyphae.TextFormat) error {
+	report := conversionReport{
+		TargetFormat: hyphae.FormatName(toFormat),
+		Hyphae:       []hyphaConversionReport{},
+	}
+
+	for _, h := range selectedHyphae {
+		oldPath := h.TextFilePath()
+		fromFormat := hyphae.DetectTextFormat(oldPath)
+
+		entry := hyphaConversionReport{
+			Hypha:      h.CanonicalName(),
+			FromFormat: hyphae.FormatName(fromFormat),
+			ToFormat:   hyphae.FormatName(toFormat),
+			Path:       relativeHyphaPath(oldPath),
+			NewPath:    relativeHyphaPath(replaceExtension(oldPath, hyphae.FormatExtension(toFormat))),
+			Warnings:   []string{},
+		}
+
+		content, err := os.ReadFile(oldPath)
+		if err != nil {
+			entry.Error = err.Error()
+			report.Hyphae = append(report.Hyphae, entry)
+			continue
+		}
+
+		convertedContent, warnings, err := converter.ConvertFormat(string(content), fromFormat, toFormat)
+		if err != nil {
+			entry.Error = err.Error()
+			report.Hyphae = append(report.Hyphae, entry)
+			continue
+		}
+		if warnings != nil {
+			entry.Warnings = warnings
+		}
+		entry.Diff = diff.Unified(entry.Path, entry.NewPath, string(content), convertedContent, 3)
+		report.Hyphae = append(report.Hyphae, entry)
+	}
+
+	sort.Slice(report.Hyphae, func(i, j int) bool {
+		return report.Hyphae[i].Hypha < report.Hyphae[j].Hypha
+	})
+
+	slog.Info("Dry run complete, nothing was written", "wouldConvert", len(report.Hyphae))
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(report)
+}
+
+// relativeHyphaPath returns the path relative to the hyphae directory, if possible.
+func relativeHyphaPath(path string) string {
+	if rel, err := filepath.Rel(files.HyphaeDir(), path); err == nil {
+		return rel
+	}
+	return path
+}
+
 // replaceExtension replaces the file extension in a path
 func replaceExtension(path, newExt string) string {
 	dir := filepath.Dir(path)
@@ -35,11 +35,17 @@ func printHelp() {
 func parseCliArgs() error {
 	var createAdminName string
 	var convertFormat string
+	var convertDryRun bool
+	var convertPrefix, convertCategory, convertExclude string
 	var versionFlag bool
 
 	flag.StringVar(&cfg.ListenAddr, "listen-addr", "", "Address to listen on. For example, 127.0.0.1:1737 or /run/mycorrhiza.sock.")
 	flag.StringVar(&createAdminName, "create-admin", "", "Create a new admin. The password will be prompted in the terminal.")
 	flag.StringVar(&convertFormat, "convert-format", "", "Convert all hyphae to the specified format (markdown or mycomarkup) and exit.")
+	flag.BoolVar(&convertDryRun, "convert-dry-run", false, "With -convert-format, write nothing and print a JSON report of the would-be conversion instead.")
+	flag.StringVar(&convertPrefix, "convert-prefix", "", "With -convert-format, convert only this hypha and its subhyphae. For example, docs/.")
+	flag.StringVar(&convertCategory, "convert-category", "", "With -convert-format, convert only hyphae in this category.")
+	flag.StringVar(&convertExclude, "convert-exclude", "", "With -convert-format, do not convert these hyphae. A comma-separated list of names or globs, like docs/drafts/*.")
 	flag.BoolVar(&versionFlag, "version", false, "Print version information and exit.")
 	flag.Usage = printHelp
 	flag.Parse()
@@ -72,7 +78,7 @@ func parseCliArgs() error {
 	}
 
 	if convertFormat != "" {
-		if err := convertFormatCommand(convertFormat); err != nil {
+		if err := convertFormatCommand(convertFormat, convertDryRun, newConversionFilter(convertPrefix, convertCategory, convertExclude)); err != nil {
 			os.Exit(1)
 		}
 		os.Exit(0)
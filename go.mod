@@ -6,18 +6,38 @@ toolchain go1.24.11
 
 require (
 	git.sr.ht/~bouncepaw/mycomarkup/v5 v5.6.0
+	github.com/go-git/go-git/v5 v5.13.2
 	github.com/go-ini/ini v1.67.0
 	github.com/gorilla/feeds v1.2.0
 	github.com/gorilla/mux v1.8.1
-	golang.org/x/crypto v0.31.0
-	golang.org/x/term v0.27.0
+	github.com/yuin/goldmark v1.7.13
+	golang.org/x/crypto v0.32.0
+	golang.org/x/term v0.28.0
 	golang.org/x/text v0.21.0
 )
 
 require (
-	github.com/stretchr/testify v1.7.0 // indirect
-	github.com/yuin/goldmark v1.7.13 // indirect
-	golang.org/x/sys v0.28.0 // indirect
+	dario.cat/mergo v1.0.0 // indirect
+	github.com/Microsoft/go-winio v0.6.1 // indirect
+	github.com/ProtonMail/go-crypto v1.1.5 // indirect
+	github.com/cloudflare/circl v1.3.7 // indirect
+	github.com/cyphar/filepath-securejoin v0.3.6 // indirect
+	github.com/emirpasic/gods v1.18.1 // indirect
+	github.com/go-git/gcfg v1.5.1-0.20230307220236-3a3c6141e376 // indirect
+	github.com/go-git/go-billy/v5 v5.6.2 // indirect
+	github.com/golang/groupcache v0.0.0-20210331224755-41bb18bfe9da // indirect
+	github.com/jbenet/go-context v0.0.0-20150711004518-d14ea06fba99 // indirect
+	github.com/kevinburke/ssh_config v1.2.0 // indirect
+	github.com/pjbgf/sha1cd v0.3.2 // indirect
+	github.com/sergi/go-diff v1.3.2-0.20230802210424-5b0b94c5c0d3 // indirect
+	github.com/skeema/knownhosts v1.3.0 // indirect
+	github.com/xanzy/ssh-agent v0.3.3 // indirect
+	golang.org/x/mod v0.17.0 // indirect
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/sync v0.10.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/tools v0.21.1-0.20240508182429-e35e4ccd0d2d // indirect
+	gopkg.in/warnings.v0 v0.1.2 // indirect
 )
 
 // Use this trick to test local Mycomarkup changes, replace the path with yours,
@@ -32,7 +32,9 @@ var (
 {{define "prevnext"}}Пред/след{{end}}
 {{define "top_bar"}}Верхняя панель{{end}}
 {{define "rename"}}Переименовывание{{end}}
+{{define "api"}}API{{end}}
 {{define "special pages"}}Специальные страницы{{end}}
+{{define "search"}}Поиск{{end}}
 {{define "recent_changes"}}Свежие правки{{end}}
 {{define "feeds"}}Ленты{{end}}
 {{define "orphans"}}Гифы-сироты{{end}}
@@ -0,0 +1,70 @@
+package history
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Activity is what the history tells about a hypha.
+type Activity struct {
+	// LastChange is the time of the latest revision of the hypha.
+	LastChange time.Time
+	// Authors are the usernames of everyone who changed the hypha.
+	Authors []string
+}
+
+// renameRecord is a rename found in the history. Affected are the hyphae the rename commit changed, under their new names.
+type renameRecord struct {
+	from, to string
+	affected []string
+}
+
+// nameAfter returns the name the hypha got in the rename. Subhyphae count only if the rename moved them too.
+func (r renameRecord) nameAfter(hyphaName string) (string, bool) {
+	switch {
+	case hyphaName == r.from:
+		return r.to, true
+	case strings.HasPrefix(hyphaName, r.from+"/"):
+		newName := r.to + strings.TrimPrefix(hyphaName, r.from)
+		return newName, slices.Contains(r.affected, newName)
+	default:
+		return "", false
+	}
+}
+
+// Activities returns the activity of every hypha in the history by the name it has now. The revisions made before a hypha was renamed count for its new name, like in Revisions. The whole history is read at once, so it is cheaper than calling Revisions for many hyphae.
+func Activities() (map[string]*Activity, error) {
+	revs, err := store.Log(logQuery{Files: true})
+	if err != nil {
+		return nil, err
+	}
+	var (
+		activities = make(map[string]*Activity)
+		// renames are newest first, like the revisions.
+		renames []renameRecord
+	)
+	for i := range revs {
+		rev := &revs[i]
+		if match := renameMsgPattern.FindStringSubmatch(rev.Message); match != nil {
+			renames = append(renames, renameRecord{match[1], match[2], rev.hyphaeAffected()})
+		}
+		for _, hyphaName := range rev.hyphaeAffected() {
+			// Replay the later renames in the order they happened.
+			for j := len(renames) - 1; j >= 0; j-- {
+				if newName, renamed := renames[j].nameAfter(hyphaName); renamed {
+					hyphaName = newName
+				}
+			}
+			activity, seen := activities[hyphaName]
+			if !seen {
+				activity = &Activity{LastChange: rev.Time}
+				activities[hyphaName] = activity
+			}
+			if !slices.Contains(activity.Authors, rev.Username) {
+				activity.Authors = append(activity.Authors, rev.Username)
+			}
+		}
+	}
+	return activities, nil
+}
@@ -0,0 +1,164 @@
+package history
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+
+	"github.com/bouncepaw/mycorrhiza/internal/files"
+	"github.com/bouncepaw/mycorrhiza/internal/mimetype"
+	"github.com/bouncepaw/mycorrhiza/util"
+)
+
+// maxRenamesFollowed limits how far Blame goes back through renames.
+const maxRenamesFollowed = 16
+
+// BlameLine is a line of the text of a hypha with the revision that last changed it.
+type BlameLine struct {
+	Revision Revision
+	// HyphaName is the name the hypha had in that revision.
+	HyphaName string
+	Number    int
+	Text      string
+}
+
+// Blame tells who last changed every line of the committed text of the hypha. Lines that came from before the hypha was renamed are traced to the old hypha. If the hypha has no text, nothing is returned.
+func Blame(hyphaName string) ([]BlameLine, error) {
+	if revs, err := store.Log(logQuery{MaxCount: 1}); err != nil || len(revs) == 0 {
+		// Nothing is committed yet
+		return nil, err
+	}
+	return blameAt(hyphaName, "HEAD", 0)
+}
+
+func blameAt(hyphaName, revHash string, depth int) ([]BlameLine, error) {
+	textPath, err := textFileAtRevision(hyphaName, revHash)
+	if err != nil || textPath == "" {
+		return nil, err
+	}
+	lines, origNumbers, err := store.Blame(textPath, revHash)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lines {
+		lines[i].HyphaName = hyphaName
+	}
+	if depth >= maxRenamesFollowed {
+		return lines, nil
+	}
+
+	// git may fail to notice that the text file was renamed, especially if links were updated in the same commit. Then the lines are blamed on the rename, and we look at the old hypha instead.
+	renamedFrom := map[string][]BlameLine{}
+	for i, line := range lines {
+		match := renameMsgPattern.FindStringSubmatch(line.Revision.Message)
+		if match == nil {
+			continue
+		}
+		oldName, found := nameBeforeRename(hyphaName, match[1], match[2])
+		if !found {
+			continue
+		}
+		key := line.Revision.Hash + "\x00" + oldName
+		oldLines, ok := renamedFrom[key]
+		if !ok {
+			oldLines, err = blameAt(oldName, line.Revision.Hash+"~", depth+1)
+			if err != nil {
+				oldLines = nil
+			}
+			renamedFrom[key] = oldLines
+		}
+		// The rename commit did not change the line if the old text had it at the same place.
+		if n := origNumbers[i]; n <= len(oldLines) && oldLines[n-1].Text == line.Text {
+			lines[i].Revision = oldLines[n-1].Revision
+			lines[i].HyphaName = oldLines[n-1].HyphaName
+		}
+	}
+	return lines, nil
+}
+
+// nameBeforeRename returns the name the hypha had before `from` was renamed to `to`. Subhyphae are renamed too, so they are checked as well.
+func nameBeforeRename(hyphaName, from, to string) (string, bool) {
+	switch {
+	case hyphaName == to:
+		return from, true
+	case strings.HasPrefix(hyphaName, to+"/"):
+		return from + strings.TrimPrefix(hyphaName, to), true
+	default:
+		return "", false
+	}
+}
+
+// textFileAtRevision returns the path of the text file the hypha had at the revision, relative to the wiki directory. It is empty if there was no such file.
+func textFileAtRevision(hyphaName, revHash string) (string, error) {
+	paths, err := HyphaFilesAtRevision(hyphaName, revHash)
+	if err != nil {
+		return "", err
+	}
+	for _, filePath := range paths {
+		if _, isText, _ := mimetype.DataFromFilename(filePath); isText {
+			return strings.TrimPrefix(filePath, files.HyphaeDir()+"/"), nil
+		}
+	}
+	return "", nil
+}
+
+// parseBlame parses the output of git blame --porcelain. It also returns the numbers the lines had in the revisions they are blamed on.
+func parseBlame(porcelain string) (lines []BlameLine, origNumbers []int) {
+	var (
+		revs    = map[string]*Revision{}
+		current *Revision
+		orig    int
+		scanner = bufio.NewScanner(strings.NewReader(porcelain))
+	)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if text, ok := strings.CutPrefix(line, "\t"); ok {
+			lines = append(lines, BlameLine{
+				Revision: *current,
+				Number:   len(lines) + 1,
+				Text:     text,
+			})
+			origNumbers = append(origNumbers, orig)
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "author-mail":
+			// Emails look like <username@mycorrhiza>
+			value = strings.Trim(value, "<>")
+			current.Username, _, _ = strings.Cut(value, "@")
+		case "author-time":
+			if tm := unixTimestampAsTime(value); tm != nil {
+				current.Time = *tm
+			}
+		case "summary":
+			current.Message = value
+		default:
+			// The header of a group of lines: <hash> <original line> <final line> [<lines in group>]
+			fields := strings.Fields(line)
+			if len(key) != 40 || len(fields) < 3 || !util.IsRevHash(key) {
+				continue
+			}
+			if current = revs[key]; current == nil {
+				current = &Revision{Hash: key[:7]}
+				revs[key] = current
+			}
+			orig, _ = strconv.Atoi(fields[1])
+		}
+	}
+	return lines, origNumbers
+}
+
+// BlameBlocks groups consecutive lines that were last changed in the same revision.
+func BlameBlocks(lines []BlameLine) [][]BlameLine {
+	var blocks [][]BlameLine
+	for i, line := range lines {
+		if i == 0 || line.Revision.Hash != lines[i-1].Revision.Hash || line.HyphaName != lines[i-1].HyphaName {
+			blocks = append(blocks, nil)
+		}
+		blocks[len(blocks)-1] = append(blocks[len(blocks)-1], line)
+	}
+	return blocks
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/bouncepaw/mycorrhiza/internal/cfg"
+	"github.com/bouncepaw/mycorrhiza/util"
 
 	"github.com/gorilla/feeds"
 )
@@ -21,12 +22,8 @@ func recentChangesFeed(opts FeedOptions) *feeds.Feed {
 		Description: fmt.Sprintf("List of %d recent changes on the wiki", changeGroupMaxSize),
 		Updated:     time.Now(),
 	}
-	revs := newRecentChangesStream()
-	groups := groupRevisions(revs, opts)
-	for _, grp := range groups {
-		item := grp.feedItem(opts)
-		feed.Add(&item)
-	}
+	revs := newFilteredRecentChangesStream(opts.filter, "")
+	addRevisionGroups(feed, revs.iterator(), opts)
 	return feed
 }
 
@@ -45,6 +42,71 @@ func RecentChangesJSON(opts FeedOptions) (string, error) {
 	return recentChangesFeed(opts).ToJSON()
 }
 
+// hyphaHistoryFeed makes a feed of the changes of the hypha, followed through renames like on the history page. If subhyphae is true, the changes of its subhyphae are included.
+func hyphaHistoryFeed(hyphaName string, subhyphae bool, opts FeedOptions) (*feeds.Feed, error) {
+	of := util.BeautifulName(hyphaName)
+	if subhyphae {
+		of += " and its subhyphae"
+	}
+	feed := &feeds.Feed{
+		Title:       fmt.Sprintf("%s (history of %s)", cfg.WikiName, of),
+		Link:        &feeds.Link{Href: cfg.URL + "/hypha/" + hyphaName},
+		Description: fmt.Sprintf("List of %d recent changes of %s", changeGroupMaxSize, of),
+		Updated:     time.Now(),
+	}
+	revs, err := revisionsFollowingRenames(hyphaName, subhyphae)
+	if err != nil {
+		return nil, err
+	}
+	nextRev := func() (Revision, bool) {
+		for len(revs) > 0 {
+			rev := revs[0]
+			revs = revs[1:]
+			if opts.filter.Matches(&rev) {
+				return rev, false
+			}
+		}
+		return Revision{}, true
+	}
+	addRevisionGroups(feed, nextRev, opts)
+	return feed, nil
+}
+
+// HyphaHistoryRSS creates the feed of the hypha's changes in RSS format. See hyphaHistoryFeed.
+func HyphaHistoryRSS(hyphaName string, subhyphae bool, opts FeedOptions) (string, error) {
+	feed, err := hyphaHistoryFeed(hyphaName, subhyphae, opts)
+	if err != nil {
+		return "", err
+	}
+	return feed.ToRss()
+}
+
+// HyphaHistoryAtom creates the feed of the hypha's changes in Atom format. See hyphaHistoryFeed.
+func HyphaHistoryAtom(hyphaName string, subhyphae bool, opts FeedOptions) (string, error) {
+	feed, err := hyphaHistoryFeed(hyphaName, subhyphae, opts)
+	if err != nil {
+		return "", err
+	}
+	return feed.ToAtom()
+}
+
+// HyphaHistoryJSON creates the feed of the hypha's changes in JSON format. See hyphaHistoryFeed.
+func HyphaHistoryJSON(hyphaName string, subhyphae bool, opts FeedOptions) (string, error) {
+	feed, err := hyphaHistoryFeed(hyphaName, subhyphae, opts)
+	if err != nil {
+		return "", err
+	}
+	return feed.ToJSON()
+}
+
+// addRevisionGroups adds the groups of revisions returned by nextRev to the feed.
+func addRevisionGroups(feed *feeds.Feed, nextRev func() (Revision, bool), opts FeedOptions) {
+	for _, grp := range groupRevisions(nextRev, opts) {
+		item := grp.feedItem(opts)
+		feed.Add(&item)
+	}
+}
+
 // revisionGroup is a slice of revisions, ordered most recent first.
 type revisionGroup []Revision
 
@@ -88,8 +150,8 @@ func groupRevisionsByMonth(revs []Revision) (res []revisionGroup) {
 // groupRevisions groups revisions for a feed.
 // It returns the first changeGroupMaxSize (30) groups.
 // The grouping parameter determines when two revisions will be grouped.
-func groupRevisions(revs recentChangesStream, opts FeedOptions) (res []revisionGroup) {
-	nextRev := revs.iterator()
+// nextRev returns successive revisions, most recent first, like recentChangesStream.iterator does.
+func groupRevisions(nextRev func() (Revision, bool), opts FeedOptions) (res []revisionGroup) {
 	rev, empty := nextRev()
 	if empty {
 		return res
@@ -178,8 +240,9 @@ type feedOptionParserState struct {
 // feedGrouping represents a set of conditions that must all be satisfied for revisions to be grouped.
 // If there are no conditions, revisions will never be grouped.
 type FeedOptions struct {
-	conds []groupingCondition
-	order feedGroupOrder
+	conds  []groupingCondition
+	order  feedGroupOrder
+	filter ChangeFilter
 }
 
 func ParseFeedOptions(query url.Values) (FeedOptions, error) {
@@ -197,6 +260,10 @@ func ParseFeedOptions(query url.Values) (FeedOptions, error) {
 	if err != nil {
 		return FeedOptions{}, err
 	}
+	filter, err := ParseChangeFilter(query)
+	if err != nil {
+		return FeedOptions{}, err
+	}
 
 	var conds []groupingCondition
 	if parser.isAnythingSet {
@@ -205,7 +272,7 @@ func ParseFeedOptions(query url.Values) (FeedOptions, error) {
 		// if no options are applied, do no grouping instead of using the default options
 		conds = nil
 	}
-	return FeedOptions{conds: conds, order: parser.order}, nil
+	return FeedOptions{conds: conds, order: parser.order, filter: filter}, nil
 }
 
 func (parser *feedOptionParserState) parseFeedGroupingPeriod(query url.Values) error {
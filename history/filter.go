@@ -0,0 +1,170 @@
+package history
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bouncepaw/mycorrhiza/util"
+)
+
+// ChangeTypes are the kinds of changes recent changes can be filtered by.
+var ChangeTypes = []string{"edit", "rename", "delete", "media", "migration"}
+
+var changeTypeOps = map[string][]OpType{
+	"edit":      {TypeEditText, TypeConvertFormat},
+	"rename":    {TypeRenameHypha},
+	"delete":    {TypeDeleteHypha},
+	"media":     {TypeEditBinary, TypeRemoveMedia},
+	"migration": {TypeMarkupMigration},
+}
+
+// opTypePrefixes tell the type of operation by the beginning of the commit message. More specific prefixes go first.
+var opTypePrefixes = []struct {
+	prefix string
+	opType OpType
+}{
+	{"Create ‘", TypeEditText},
+	{"Edit ‘", TypeEditText},
+	{"Revert ‘", TypeEditText},
+	{"Restore ‘", TypeEditText},
+	{"Convert ‘", TypeConvertFormat},
+	{"Rename ‘", TypeRenameHypha},
+	{"Delete ‘", TypeDeleteHypha},
+	{"Upload media for ‘", TypeEditBinary},
+	{"Remove media from ‘", TypeRemoveMedia},
+	{"Migrate ", TypeMarkupMigration},
+	{"Convert ", TypeMarkupMigration},
+}
+
+// OpType guesses the type of the operation that made the revision from its message. It is TypeNone if the message is unusual.
+func (rev Revision) OpType() OpType {
+	for _, p := range opTypePrefixes {
+		if strings.HasPrefix(rev.Message, p.prefix) {
+			return p.opType
+		}
+	}
+	return TypeNone
+}
+
+// ChangeFilter selects revisions for recent changes and web feeds. The zero filter selects everything.
+type ChangeFilter struct {
+	// Author is the username of the author.
+	Author string
+	// Types are some of ChangeTypes. If there are none, changes of all types are selected.
+	Types []string
+	// HyphaPrefix selects the changes of the hypha with this name and of its subhyphae. It has no trailing slash.
+	HyphaPrefix string
+	// Since and Until limit the time of the changes. Until is not included. Zero times limit nothing.
+	Since, Until time.Time
+}
+
+// dateLayout is how dates are written in the filter parameters.
+const dateLayout = "2006-01-02"
+
+// ParseChangeFilter reads the filter from the query parameters author, type (might be repeated), prefix, since and until. The dates look like 2006-01-02, both are included.
+func ParseChangeFilter(query url.Values) (ChangeFilter, error) {
+	filter := ChangeFilter{
+		Author: strings.TrimSpace(query.Get("author")),
+	}
+	if prefix := strings.TrimSpace(query.Get("prefix")); prefix != "" {
+		filter.HyphaPrefix = strings.TrimSuffix(util.CanonicalName(prefix), "/")
+	}
+	for _, changeType := range query["type"] {
+		if changeType == "" {
+			continue
+		}
+		if _, ok := changeTypeOps[changeType]; !ok {
+			return ChangeFilter{}, errors.New("unknown type option " + changeType)
+		}
+		if !slices.Contains(filter.Types, changeType) {
+			filter.Types = append(filter.Types, changeType)
+		}
+	}
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(dateLayout, since)
+		if err != nil {
+			return ChangeFilter{}, err
+		}
+		filter.Since = t
+	}
+	if until := query.Get("until"); until != "" {
+		t, err := time.Parse(dateLayout, until)
+		if err != nil {
+			return ChangeFilter{}, err
+		}
+		filter.Until = t.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
+// Values returns the query parameters ParseChangeFilter reads the filter from.
+func (f ChangeFilter) Values() url.Values {
+	values := url.Values{}
+	if f.Author != "" {
+		values.Set("author", f.Author)
+	}
+	for _, changeType := range f.Types {
+		values.Add("type", changeType)
+	}
+	if f.HyphaPrefix != "" {
+		values.Set("prefix", f.HyphaPrefix)
+	}
+	if since := f.SinceDate(); since != "" {
+		values.Set("since", since)
+	}
+	if until := f.UntilDate(); until != "" {
+		values.Set("until", until)
+	}
+	return values
+}
+
+// HasType tells whether the filter selects the given type explicitly.
+func (f ChangeFilter) HasType(changeType string) bool {
+	return slices.Contains(f.Types, changeType)
+}
+
+// SinceDate returns the first day of the changes, or an empty string if there is no such limit.
+func (f ChangeFilter) SinceDate() string {
+	if f.Since.IsZero() {
+		return ""
+	}
+	return f.Since.Format(dateLayout)
+}
+
+// UntilDate returns the last day of the changes, or an empty string if there is no such limit.
+func (f ChangeFilter) UntilDate() string {
+	if f.Until.IsZero() {
+		return ""
+	}
+	return f.Until.AddDate(0, 0, -1).Format(dateLayout)
+}
+
+// Matches tells whether the filter selects the revision.
+func (f ChangeFilter) Matches(rev *Revision) bool {
+	if f.Author != "" && rev.Username != f.Author {
+		return false
+	}
+	if !f.Since.IsZero() && rev.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !rev.Time.Before(f.Until) {
+		return false
+	}
+	if len(f.Types) > 0 {
+		opType := rev.OpType()
+		if !slices.ContainsFunc(f.Types, func(changeType string) bool {
+			return slices.Contains(changeTypeOps[changeType], opType)
+		}) {
+			return false
+		}
+	}
+	if f.HyphaPrefix != "" {
+		return slices.ContainsFunc(rev.hyphaeAffected(), func(hyphaName string) bool {
+			return hyphaName == f.HyphaPrefix || strings.HasPrefix(hyphaName, f.HyphaPrefix+"/")
+		})
+	}
+	return true
+}
@@ -6,49 +6,23 @@ import (
 	"fmt"
 	"log/slog"
 	"os/exec"
-	"path/filepath"
 	"regexp"
 
 	"github.com/bouncepaw/mycorrhiza/internal/files"
 	"github.com/bouncepaw/mycorrhiza/util"
 )
 
-// Path to git executable. Set at init()
+// Path to git executable. Set in Start
 var gitpath string
 
-var renameMsgPattern = regexp.MustCompile(`^Rename ‘(.*)’ to ‘.*’`)
+var renameMsgPattern = regexp.MustCompile(`^Rename ‘(.*)’ to ‘(.*)’`)
 
 var gitEnv = []string{"GIT_COMMITTER_NAME=wikimind", "GIT_COMMITTER_EMAIL=wikimind@mycorrhiza"}
 
-// Start finds git and initializes git credentials.
-func Start() error {
-	path, err := exec.LookPath("git")
-	if err != nil {
-		slog.Error("Could not find the Git executable. Check your $PATH.")
-		return err
-	}
-	gitpath = path
-	return nil
-}
-
 // InitGitRepo checks a Git repository and initializes it if necessary.
 func InitGitRepo() {
-	// Detect if the Git repo directory is a Git repository
-	isGitRepo := true
-	buf, err := silentGitsh("rev-parse", "--git-dir")
-	if err != nil {
-		isGitRepo = false
-	}
-	if isGitRepo {
-		gitDir := buf.String()
-		if filepath.IsAbs(gitDir) && !filepath.HasPrefix(gitDir, files.HyphaeDir()) {
-			isGitRepo = false
-		}
-	}
-	if !isGitRepo {
-		slog.Info("Initializing Git repo", "path", files.HyphaeDir())
-		gitsh("init")
-		gitsh("config", "core.quotePath", "false")
+	if err := store.Init(); err != nil {
+		slog.Error("Failed to initialize Git repo", "path", files.HyphaeDir(), "err", err)
 	}
 }
 
@@ -77,11 +51,10 @@ func silentGitsh(args ...string) (out bytes.Buffer, err error) {
 	return *bytes.NewBuffer(b), err
 }
 
-// Rename renames from `from` to `to` using `git mv`.
+// Rename renames from `from` to `to` like `git mv` does.
 func Rename(from, to string) error {
 	slog.Info("Renaming file with git mv",
 		"from", util.ShorterPath(from),
 		"to", util.ShorterPath(to))
-	_, err := gitsh("mv", "--force", from, to)
-	return err
+	return store.Move(from, to)
 }
@@ -0,0 +1,38 @@
+package histweb
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/bouncepaw/mycorrhiza/history"
+	"github.com/bouncepaw/mycorrhiza/internal/cfg"
+	"github.com/bouncepaw/mycorrhiza/util"
+	"github.com/bouncepaw/mycorrhiza/web/viewutil"
+)
+
+// handlerBlame shows who last changed every line of the hypha's text.
+func handlerBlame(w http.ResponseWriter, rq *http.Request) {
+	util.PrepareRq(rq)
+	hyphaName := util.HyphaNameFromRq(rq, "blame")
+	lines, err := history.Blame(hyphaName)
+	if err != nil {
+		slog.Error("Failed to blame hypha", "hyphaName", hyphaName, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	viewutil.ExecutePage(viewutil.MetaFrom(w, rq), chainBlame, blameData{
+		BaseData: &viewutil.BaseData{
+			Addr: "/blame/" + hyphaName,
+		},
+		HyphaName: hyphaName,
+		Blocks:    history.BlameBlocks(lines),
+		UserHypha: cfg.UserHypha,
+	})
+}
+
+type blameData struct {
+	*viewutil.BaseData
+	HyphaName string
+	Blocks    [][]history.BlameLine
+	UserHypha string
+}
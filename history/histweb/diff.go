@@ -0,0 +1,163 @@
+package histweb
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/bouncepaw/mycorrhiza/history"
+	"github.com/bouncepaw/mycorrhiza/internal/diff"
+	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/mimetype"
+	"github.com/bouncepaw/mycorrhiza/internal/renderer"
+	"github.com/bouncepaw/mycorrhiza/util"
+	"github.com/bouncepaw/mycorrhiza/web/viewutil"
+)
+
+// revisionCurrent stands for the current text of the hypha, which might be not committed yet.
+const revisionCurrent = "current"
+
+// diffContext is how many equal lines are shown around changes.
+const diffContext = 3
+
+// handlerDiff compares two revisions of a hypha. Query parameters: from and to are revision hashes, to might be current. If from is omitted, the revision before to is used. view is inline, side-by-side or rendered.
+func handlerDiff(w http.ResponseWriter, rq *http.Request) {
+	util.PrepareRq(rq)
+	var (
+		hyphaName = util.HyphaNameFromRq(rq, "diff")
+		query     = rq.URL.Query()
+		from      = query.Get("from")
+		to        = query.Get("to")
+		view      = query.Get("view")
+	)
+	if to == "" {
+		to = revisionCurrent
+	}
+	if (from != "" && !util.IsRevHash(from)) || (to != revisionCurrent && !util.IsRevHash(to)) {
+		http.Error(w, "400 bad request", http.StatusBadRequest)
+		return
+	}
+	switch view {
+	case "side-by-side", "rendered":
+	default:
+		view = "inline"
+	}
+	revs, err := history.Revisions(hyphaName)
+	if err != nil {
+		slog.Error("Failed to find revisions", "hyphaName", hyphaName, "err", err)
+	}
+	if from == "" {
+		from = revisionBefore(revs, to)
+	}
+
+	var (
+		fromName, toName     = nameAt(revs, hyphaName, from), nameAt(revs, hyphaName, to)
+		fromText, fromFormat = textAt(fromName, from)
+		toText, toFormat     = textAt(toName, to)
+		data                 = diffData{
+			BaseData: &viewutil.BaseData{
+				Addr: "/diff/" + hyphaName,
+			},
+			HyphaName: hyphaName,
+			From:      from,
+			To:        to,
+			FromName:  fromName,
+			ToName:    toName,
+			View:      view,
+			Same:      fromText == toText,
+		}
+	)
+	switch view {
+	case "rendered":
+		fromHTML, _ := renderer.RenderForPreview(fromText, fromFormat, hyphaName)
+		toHTML, _ := renderer.RenderForPreview(toText, toFormat, hyphaName)
+		data.Rendered = template.HTML(diff.HTML(string(fromHTML), string(toHTML)))
+	case "side-by-side":
+		for _, hunk := range diff.Hunks(diff.LineDiff(fromText, toText), diffContext) {
+			data.SideBySide = append(data.SideBySide, diff.SideBySide(hunk))
+		}
+	default:
+		data.Inline = diff.Hunks(diff.LineDiff(fromText, toText), diffContext)
+	}
+	viewutil.ExecutePage(viewutil.MetaFrom(w, rq), chainDiff, data)
+}
+
+type diffData struct {
+	*viewutil.BaseData
+	HyphaName string
+	From, To  string
+	View      string
+	// FromName and ToName are the names the hypha had in the revisions.
+	FromName, ToName string
+	// Same is true when the texts are the same
+	Same bool
+	// Only one of these is set, depending on the view.
+	Inline     [][]diff.Line
+	SideBySide [][]diff.Row
+	Rendered   template.HTML
+}
+
+// revisionBefore returns the revision of the hypha that goes before the given one. If there is none, the hash is empty, which means no text.
+func revisionBefore(revs []history.Revision, revHash string) string {
+	i := 0 // The current text is usually the last revision
+	if revHash != revisionCurrent {
+		i = indexOfRevision(revs, revHash)
+	}
+	if i < 0 || i+1 >= len(revs) {
+		return ""
+	}
+	return revs[i+1].Hash
+}
+
+// nameAt returns the name the hypha had at the revision. It might differ from the current one if the hypha was renamed.
+func nameAt(revs []history.Revision, hyphaName, revHash string) string {
+	if i := indexOfRevision(revs, revHash); i >= 0 && revs[i].HyphaName != "" {
+		return revs[i].HyphaName
+	}
+	return hyphaName
+}
+
+// indexOfRevision finds the revision by its hash, which might be shorter or longer than the one in revs.
+func indexOfRevision(revs []history.Revision, revHash string) int {
+	if revHash == "" || revHash == revisionCurrent {
+		return -1
+	}
+	return slices.IndexFunc(revs, func(rev history.Revision) bool {
+		return history.SameRevision(rev.Hash, revHash)
+	})
+}
+
+// textAt returns the text of the hypha at the revision and its format. The text is empty if the hypha had no text then.
+func textAt(hyphaName, revHash string) (string, hyphae.TextFormat) {
+	if revHash == "" {
+		return "", hyphae.FormatMycomarkup
+	}
+	if revHash == revisionCurrent {
+		h, ok := hyphae.ByName(hyphaName).(hyphae.ExistingHypha)
+		if !ok || !h.HasTextFile() {
+			return "", hyphae.FormatMycomarkup
+		}
+		text, err := os.ReadFile(h.TextFilePath())
+		if err != nil {
+			slog.Error("Failed to read text of hypha", "hyphaName", hyphaName, "err", err)
+		}
+		return string(text), hyphae.DetectTextFormat(h.TextFilePath())
+	}
+
+	paths, err := history.HyphaFilesAtRevision(hyphaName, revHash)
+	if err != nil {
+		slog.Error("Failed to find files of hypha at revision", "hyphaName", hyphaName, "revHash", revHash, "err", err)
+	}
+	for _, path := range paths {
+		if _, isText, _ := mimetype.DataFromFilename(path); isText {
+			text, err := history.FileAtRevision(path, revHash)
+			if err != nil {
+				slog.Error("Failed to read text of hypha at revision", "hyphaName", hyphaName, "revHash", revHash, "err", err)
+			}
+			return text, hyphae.DetectTextFormat(path)
+		}
+	}
+	return "", hyphae.FormatMycomarkup
+}
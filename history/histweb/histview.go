@@ -23,6 +23,8 @@ import (
 
 func InitHandlers(rtr *mux.Router) {
 	rtr.PathPrefix("/primitive-diff/").HandlerFunc(handlerPrimitiveDiff)
+	rtr.PathPrefix("/diff/").HandlerFunc(handlerDiff)
+	rtr.PathPrefix("/blame/").HandlerFunc(handlerBlame)
 	rtr.HandleFunc("/recent-changes/{count:[0-9]+}", handlerRecentChanges)
 	rtr.HandleFunc("/recent-changes/", func(w http.ResponseWriter, rq *http.Request) {
 		http.Redirect(w, rq, "/recent-changes/20", http.StatusSeeOther)
@@ -31,8 +33,13 @@ func InitHandlers(rtr *mux.Router) {
 	rtr.HandleFunc("/recent-changes-rss", handlerRecentChangesRSS)
 	rtr.HandleFunc("/recent-changes-atom", handlerRecentChangesAtom)
 	rtr.HandleFunc("/recent-changes-json", handlerRecentChangesJSON)
+	rtr.PathPrefix("/history-rss/").HandlerFunc(handlerHistoryRSS)
+	rtr.PathPrefix("/history-atom/").HandlerFunc(handlerHistoryAtom)
+	rtr.PathPrefix("/history-json/").HandlerFunc(handlerHistoryJSON)
 
 	chainPrimitiveDiff = viewutil.CopyEnRuWith(fs, "view_primitive_diff.html", ruTranslation)
+	chainDiff = viewutil.CopyEnRuWith(fs, "view_diff.html", ruTranslation)
+	chainBlame = viewutil.CopyEnRuWith(fs, "view_blame.html", ruTranslation)
 	chainRecentChanges = viewutil.CopyEnRuWith(fs, "view_recent_changes.html", ruTranslation)
 	chainHistory = viewutil.CopyEnRuWith(fs, "view_history.html", ruTranslation)
 }
@@ -70,7 +77,33 @@ func handlerRecentChanges(w http.ResponseWriter, rq *http.Request) {
 	if editCount > 100 {
 		return
 	}
-	recentChanges(viewutil.MetaFrom(w, rq), editCount, history.RecentChanges(editCount))
+	var (
+		query       = rq.URL.Query()
+		after       = query.Get("after")
+		before      = query.Get("before")
+		filter, err = history.ParseChangeFilter(query)
+	)
+	if err != nil || (after != "" && !util.IsRevHash(after)) || (before != "" && !util.IsRevHash(before)) {
+		http.Error(w, "400 bad request", http.StatusBadRequest)
+		return
+	}
+	changes, hasNewer, hasOlder := history.RecentChangesPage(filter, editCount, after, before)
+	data := recentChangesData{
+		BaseData:  &viewutil.BaseData{},
+		EditCount: editCount,
+		Changes:   changes,
+		UserHypha: cfg.UserHypha,
+		Stops:     []int{20, 50, 100},
+		Filter:    filter,
+		Query:     template.URL(filter.Values().Encode()),
+	}
+	if len(changes) > 0 && hasNewer {
+		data.Newer = changes[0].Hash
+	}
+	if len(changes) > 0 && hasOlder {
+		data.Older = changes[len(changes)-1].Hash
+	}
+	viewutil.ExecutePage(viewutil.MetaFrom(w, rq), chainRecentChanges, data)
 }
 
 // handlerHistory lists all revisions of a hypha.
@@ -121,25 +154,85 @@ func handlerRecentChangesJSON(w http.ResponseWriter, rq *http.Request) {
 	genericHandlerOfFeeds(w, rq, history.RecentChangesJSON, "JSON feed", "application/feed+json")
 }
 
+// genericHandlerOfHyphaFeeds is genericHandlerOfFeeds for the feeds of one hypha. The subhyphae query parameter tells whether its subhyphae are included.
+func genericHandlerOfHyphaFeeds(w http.ResponseWriter, rq *http.Request, action string, f func(string, bool, history.FeedOptions) (string, error), name string, contentType string) {
+	hyphaName := util.HyphaNameFromRq(rq, action)
+	genericHandlerOfFeeds(w, rq, func(opts history.FeedOptions) (string, error) {
+		var subhyphae bool
+		if value := rq.URL.Query().Get("subhyphae"); value != "" {
+			var err error
+			if subhyphae, err = strconv.ParseBool(value); err != nil {
+				return "", err
+			}
+		}
+		return f(hyphaName, subhyphae, opts)
+	}, name, contentType)
+}
+
+func handlerHistoryRSS(w http.ResponseWriter, rq *http.Request) {
+	genericHandlerOfHyphaFeeds(w, rq, "history-rss", history.HyphaHistoryRSS, "RSS", "application/rss+xml")
+}
+
+func handlerHistoryAtom(w http.ResponseWriter, rq *http.Request) {
+	genericHandlerOfHyphaFeeds(w, rq, "history-atom", history.HyphaHistoryAtom, "Atom", "application/atom+xml")
+}
+
+func handlerHistoryJSON(w http.ResponseWriter, rq *http.Request) {
+	genericHandlerOfHyphaFeeds(w, rq, "history-json", history.HyphaHistoryJSON, "JSON feed", "application/feed+json")
+}
+
 var (
 	//go:embed *.html
 	fs            embed.FS
 	ruTranslation = `
 {{define "history of title"}}История «{{.}}»{{end}}
 {{define "history of heading"}}История <a href="/hypha/{{.}}">{{beautifulName .}}</a>{{end}}
+{{define "subscribe to hypha via"}}Подписаться на историю гифы через <a href="/history-rss/{{.}}">RSS</a>, <a href="/history-atom/{{.}}">Atom</a> или <a href="/history-json/{{.}}">JSON-ленту</a>.{{end}}
+{{define "subscribe to subhyphae via"}}Подписаться на историю гифы и её подгиф через <a href="/history-rss/{{.}}?subhyphae=true">RSS</a>, <a href="/history-atom/{{.}}?subhyphae=true">Atom</a> или <a href="/history-json/{{.}}?subhyphae=true">JSON-ленту</a>.{{end}}
 
 {{define "diff for at title"}}Разница для {{beautifulName .HyphaName}} для {{.Hash}}{{end}}
 {{define "diff for at heading"}}Разница для <a href="/hypha/{{.HyphaName}}">{{beautifulName .HyphaName}}</a> для {{.Hash}}{{end}}
 {{define "no text diff available"}}Нет текстовой разницы.{{end}}
+{{define "compare revisions"}}Сравнить в других видах{{end}}
+
+{{define "diff title"}}Разница для {{beautifulName .HyphaName}}{{end}}
+{{define "diff heading"}}Разница для <a href="/hypha/{{.HyphaName}}">{{beautifulName .HyphaName}}</a>{{end}}
+{{define "no revision"}}ничего{{end}}
+{{define "current version"}}текущая версия{{end}}
+{{define "history"}}История{{end}}
+{{define "inline"}}Построчно{{end}}
+{{define "side by side"}}Рядом{{end}}
+{{define "rendered"}}Как на странице{{end}}
+{{define "no differences"}}Тексты совпадают.{{end}}
+{{define "compare selected"}}Сравнить выбранные ревизии{{end}}
+
+{{define "blame title"}}Авторство {{beautifulName .HyphaName}}{{end}}
+{{define "blame heading"}}Авторство <a href="/hypha/{{.HyphaName}}">{{beautifulName .HyphaName}}</a>{{end}}
+{{define "blame empty"}}У этой гифы нет текста.{{end}}
 
 {{define "count pre"}}Отобразить{{end}}
 {{define "count post"}}свежих правок.{{end}}
-{{define "subscribe via"}}Подписаться через <a href="/recent-changes-rss">RSS</a>, <a href="/recent-changes-atom">Atom</a> или <a href="/recent-changes-json">JSON-ленту</a>.{{end}}
+{{define "subscribe via"}}Подписаться через <a href="/recent-changes-rss{{with .Query}}?{{.}}{{end}}">RSS</a>, <a href="/recent-changes-atom{{with .Query}}?{{.}}{{end}}">Atom</a> или <a href="/recent-changes-json{{with .Query}}?{{.}}{{end}}">JSON-ленту</a>.{{end}}
+{{define "filter"}}Отбор{{end}}
+{{define "filter author"}}Автор{{end}}
+{{define "filter prefix"}}Гифы, начинающиеся с{{end}}
+{{define "filter since"}}С{{end}}
+{{define "filter until"}}По{{end}}
+{{define "filter types"}}Виды правок{{end}}
+{{define "type edit"}}Правки{{end}}
+{{define "type rename"}}Переименования{{end}}
+{{define "type delete"}}Удаления{{end}}
+{{define "type media"}}Медиа{{end}}
+{{define "type migration"}}Миграции{{end}}
+{{define "filter apply"}}Применить{{end}}
+{{define "filter reset"}}Сбросить{{end}}
+{{define "newer changes"}}← Новее{{end}}
+{{define "older changes"}}Старее →{{end}}
 {{define "recent changes"}}Свежие правки{{end}}
 {{define "n recent changes"}}{{.}} свеж{{if eq . 1}}ая правка{{else if le . 4}}их правок{{else}}их правок{{end}}{{end}}
 {{define "recent empty"}}Правки не найдены.{{end}}
 `
-	chainPrimitiveDiff, chainRecentChanges, chainHistory viewutil.Chain
+	chainPrimitiveDiff, chainDiff, chainBlame, chainRecentChanges, chainHistory viewutil.Chain
 )
 
 type recentChangesData struct {
@@ -148,16 +241,11 @@ type recentChangesData struct {
 	Changes   []history.Revision
 	UserHypha string
 	Stops     []int
-}
-
-func recentChanges(meta viewutil.Meta, editCount int, changes []history.Revision) {
-	viewutil.ExecutePage(meta, chainRecentChanges, recentChangesData{
-		BaseData:  &viewutil.BaseData{},
-		EditCount: editCount,
-		Changes:   changes,
-		UserHypha: cfg.UserHypha,
-		Stops:     []int{20, 50, 100},
-	})
+	Filter    history.ChangeFilter
+	// Query has the filter in the form of query parameters, it might be empty.
+	Query template.URL
+	// Newer and Older are the cursors for the next pages. They are empty if there are no such pages.
+	Newer, Older string
 }
 
 type primitiveDiffData struct {
@@ -3,9 +3,9 @@ package history
 // history/operations.go
 // 	Things related to writing history.
 import (
-	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/bouncepaw/mycorrhiza/internal/user"
@@ -33,16 +33,19 @@ const (
 	TypeRemoveMedia
 	// TypeMarkupMigration represents a wikimind-powered automatic markup migration procedure
 	TypeMarkupMigration
+	// TypeConvertFormat represents a conversion of hypha text part to another markup format
+	TypeConvertFormat
 )
 
 // Op is an object representing a history operation.
 type Op struct {
 	// All errors are appended here.
-	Errs    []error
-	Type    OpType
-	userMsg string
-	name    string
-	email   string
+	Errs     []error
+	Type     OpType
+	userMsg  string
+	trailers []string
+	name     string
+	email    string
 }
 
 // Operation is a constructor of a history operation.
@@ -57,11 +60,9 @@ func Operation(opType OpType) *Op {
 	return hop
 }
 
-// git operation maker helper
-func (hop *Op) gitop(args ...string) *Op {
-	out, err := gitsh(args...)
+// withStorageErr appends the `err` to the list of errors if it is not nil.
+func (hop *Op) withStorageErr(err error) *Op {
 	if err != nil {
-		fmt.Println("out:", out.String())
 		hop.Errs = append(hop.Errs, err)
 	}
 	return hop
@@ -78,18 +79,18 @@ func (hop *Op) WithErrAbort(err error) *Op {
 	return hop.withErr(err).Abort()
 }
 
-// WithFilesRemoved git-rm-s all passed `paths`. Paths can be rooted or not. Paths that are empty strings are ignored.
+// WithFilesRemoved removes all passed `paths` like `git rm` does. Paths can be rooted or not. Paths that are empty strings are ignored.
 func (hop *Op) WithFilesRemoved(paths ...string) *Op {
-	args := []string{"rm", "--quiet", "--"}
+	var nonEmpty []string
 	for _, path := range paths {
 		if path != "" {
-			args = append(args, path)
+			nonEmpty = append(nonEmpty, path)
 		}
 	}
-	return hop.gitop(args...)
+	return hop.withStorageErr(store.Remove(nonEmpty...))
 }
 
-// WithFilesRenamed git-mv-s all passed keys of `pairs` to values of `pairs`. Paths can be rooted ot not. Empty keys are ignored.
+// WithFilesRenamed renames all passed keys of `pairs` to values of `pairs`. Paths can be rooted ot not. Empty keys are ignored.
 func (hop *Op) WithFilesRenamed(pairs map[string]string) *Op {
 	for from, to := range pairs {
 		if from != "" {
@@ -97,7 +98,7 @@ func (hop *Op) WithFilesRenamed(pairs map[string]string) *Op {
 				hop.Errs = append(hop.Errs, err)
 				continue
 			}
-			hop.gitop("mv", "--force", from, to)
+			hop.withStorageErr(store.Move(from, to))
 		}
 	}
 	return hop
@@ -109,17 +110,16 @@ func (hop *Op) WithFiles(paths ...string) *Op {
 		paths[i] = util.ShorterPath(path)
 	}
 	// 1 git operation is more effective than n operations.
-	return hop.gitop(append([]string{"add"}, paths...)...)
+	return hop.withStorageErr(store.Add(paths...))
 }
 
 // Apply applies history operation by doing the commit. You do not need to call Abort afterwards.
 func (hop *Op) Apply() *Op {
-	hop.gitop(
-		"commit",
-		"--author='"+hop.name+" <"+hop.email+">'",
-		"--message="+hop.userMsg,
-		"--no-gpg-sign",
-	)
+	message := hop.userMsg
+	if len(hop.trailers) > 0 {
+		message += "\n\n" + strings.Join(hop.trailers, "\n")
+	}
+	hop.withStorageErr(store.Commit(hop.name, hop.email, message))
 	gitMutex.Unlock()
 	return hop
 }
@@ -141,6 +141,12 @@ func (hop *Op) WithMsg(userMsg string) *Op {
 	return hop
 }
 
+// WithTrailer adds a `key: value` line to the end of the commit message. Read them back with TrailerValues.
+func (hop *Op) WithTrailer(key, value string) *Op {
+	hop.trailers = append(hop.trailers, key+": "+strings.ReplaceAll(value, "\n", " "))
+	return hop
+}
+
 // WithUser sets a user for the commit.
 func (hop *Op) WithUser(u *user.User) *Op {
 	if u.Group != "anon" {
@@ -6,6 +6,8 @@ import (
 	"log/slog"
 	"net/url"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -13,11 +15,22 @@ import (
 
 	"github.com/bouncepaw/mycorrhiza/internal/cfg"
 	"github.com/bouncepaw/mycorrhiza/internal/files"
+	"github.com/bouncepaw/mycorrhiza/util"
 )
 
 // WithRevisions returns an HTML representation of `revs` that is meant to be inserted in a history page.
 func WithRevisions(hyphaName string, revs []Revision) string {
-	var buf strings.Builder
+	var (
+		buf strings.Builder
+		// The radio buttons choose the revisions to compare. The last two are chosen by default.
+		n       int
+		checked = func(i int) string {
+			if n == i {
+				return " checked"
+			}
+			return ""
+		}
+	)
 
 	for _, grp := range groupRevisionsByMonth(revs) {
 		currentYear := grp[0].Time.Year()
@@ -34,19 +47,34 @@ func WithRevisions(hyphaName string, revs []Revision) string {
 		))
 
 		for _, rev := range grp {
+			revHyphaName := hyphaName
+			if rev.HyphaName != "" {
+				revHyphaName = rev.HyphaName
+			}
 			buf.WriteString(fmt.Sprintf(
 				`<li class="history__entry">
+	<input type="radio" name="from" value="%s" class="history-entry__compare" aria-label="from"%s>
+	<input type="radio" name="to" value="%s" class="history-entry__compare" aria-label="to"%s>
 	<a class="history-entry" href="/rev/%s/%s">
 		<time class="history-entry__time">%s</time>
 	</a>
 	<span class="history-entry__hash"><a href="/primitive-diff/%s/%s">%s</a></span>
 	<span class="history-entry__msg">%s</span>`,
-				rev.Hash, hyphaName,
+				rev.Hash, checked(1),
+				rev.Hash, checked(0),
+				rev.Hash, revHyphaName,
 				rev.timeToDisplay(),
-				rev.Hash, hyphaName, rev.Hash,
+				rev.Hash, revHyphaName, rev.Hash,
 				html.EscapeString(rev.Message),
 			))
 
+			if revHyphaName != hyphaName {
+				buf.WriteString(fmt.Sprintf(
+					`<span class="history-entry__name">as <a href="/hypha/%s">%s</a></span>`,
+					revHyphaName, util.BeautifulName(revHyphaName),
+				))
+			}
+
 			if rev.Username != "anon" {
 				buf.WriteString(fmt.Sprintf(
 					`<span class="history-entry__author">by <a href="/hypha/%s/%s" rel="author">%s</a></span>`,
@@ -55,6 +83,7 @@ func WithRevisions(hyphaName string, revs []Revision) string {
 			}
 
 			buf.WriteString("</li>\n")
+			n++
 		}
 
 		buf.WriteString(`</ul></section>`)
@@ -67,6 +96,8 @@ func WithRevisions(hyphaName string, revs []Revision) string {
 type Revision struct {
 	// Hash is usually short.
 	Hash string
+	// HyphaName is the name the hypha had in the revision. It is set by Revisions only.
+	HyphaName string
 	// Username is extracted from email.
 	Username          string
 	Time              time.Time
@@ -131,37 +162,12 @@ func (rev Revision) HyphaeLinksHTML() string {
 	return buf.String()
 }
 
-// gitLog calls `git log` and parses the results.
-func gitLog(args ...string) ([]Revision, error) {
-	args = append([]string{
-		"log", "--abbrev-commit", "--no-merges",
-		"--pretty=format:%h\t%ae\t%at\t%s",
-	}, args...)
-	args = append(args, "--")
-	out, err := silentGitsh(args...)
-	if strings.Contains(out.String(), "bad revision 'HEAD'") {
-		// Then we have no recent changes! It's a hack.
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-
-	outStr := out.String()
-	if outStr == "" {
-		// if there are no commits to return
-		return nil, nil
-	}
-
-	var revs []Revision
-	for _, line := range strings.Split(outStr, "\n") {
-		revs = append(revs, parseRevisionLine(line))
-	}
-	return revs, nil
-}
-
 type recentChangesStream struct {
 	currHash string
+	// until is the revision the stream stops at. If empty, the stream goes to the first revision.
+	until string
+	// filter is applied by the iterator only.
+	filter ChangeFilter
 }
 
 func newRecentChangesStream() recentChangesStream {
@@ -170,21 +176,34 @@ func newRecentChangesStream() recentChangesStream {
 	return recentChangesStream{currHash: ""}
 }
 
+// newFilteredRecentChangesStream makes a stream whose iterator returns the revisions that match the filter. It starts after the revision with the given hash, or with the latest revision if the hash is empty.
+func newFilteredRecentChangesStream(filter ChangeFilter, after string) recentChangesStream {
+	return recentChangesStream{currHash: after, filter: filter}
+}
+
 func (stream *recentChangesStream) next(n int) []Revision {
-	args := []string{"--max-count=" + strconv.Itoa(n)}
-	if stream.currHash == "" {
-		args = append(args, "HEAD")
-	} else {
-		// currHash is the last revision from the last call, so skip it
-		args = append(args, "--skip=1", stream.currHash)
+	q := logQuery{MaxCount: n, Until: stream.until}
+	if stream.currHash != "" {
+		// currHash is the last revision from the last call, so skip it. It is not there if it does not match the paths.
+		q.From, q.MaxCount = stream.currHash, n+1
+	}
+	if prefix := stream.filter.HyphaPrefix; prefix != "" {
+		// Let Git skip the other hyphae. The filter checks the names more precisely, and it needs the files anyway.
+		q.Paths = []string{prefix + ".*", prefix + "/*"}
+		q.Files = true
 	}
 
-	res, err := gitLog(args...)
+	res, err := store.Log(q)
 	if err != nil {
 		// TODO: return error
 		slog.Error("Failed to git log", "err", err)
 		os.Exit(1)
 	}
+	if stream.currHash != "" && len(res) != 0 && SameRevision(res[0].Hash, stream.currHash) {
+		res = res[1:]
+	} else if len(res) > n {
+		res = res[:n]
+	}
 	if len(res) != 0 {
 		stream.currHash = res[len(res)-1].Hash
 	}
@@ -192,22 +211,30 @@ func (stream *recentChangesStream) next(n int) []Revision {
 	return res
 }
 
-// recentChangesIterator returns a function that returns successive revisions from the stream.
+// recentChangesIterator returns a function that returns successive revisions from the stream that match its filter.
 // It buffers revisions to avoid calling git every time.
 func (stream recentChangesStream) iterator() func() (Revision, bool) {
 	var buf []Revision
 	return func() (Revision, bool) {
-		if len(buf) == 0 {
-			// no real reason to choose 30, just needs some large number
-			buf = stream.next(30)
+		for {
 			if len(buf) == 0 {
-				// revs has no revisions left
+				// no real reason to choose 30, just needs some large number
+				buf = stream.next(30)
+				if len(buf) == 0 {
+					// revs has no revisions left
+					return Revision{}, true
+				}
+			}
+			rev := buf[0]
+			buf = buf[1:]
+			if !stream.filter.Since.IsZero() && rev.Time.Before(stream.filter.Since) {
+				// The rest are even older
 				return Revision{}, true
 			}
+			if stream.filter.Matches(&rev) {
+				return rev, false
+			}
 		}
-		rev := buf[0]
-		buf = buf[1:]
-		return rev, false
 	}
 }
 
@@ -219,17 +246,165 @@ func RecentChanges(n int) []Revision {
 	return revs
 }
 
-// Revisions returns slice of revisions for the given hypha name, ordered most recent first.
+// RecentChangesPage returns at most n latest changes that match the filter, ordered most recent first. If after is set, the page starts with the change that goes after the revision with that hash. If before is set, the page ends with the change that goes before it. It also tells if there are more changes on newer and older pages.
+func RecentChangesPage(filter ChangeFilter, n int, after, before string) (revs []Revision, hasNewer, hasOlder bool) {
+	if before != "" {
+		if _, err := store.Log(logQuery{From: before, MaxCount: 1}); err == nil {
+			// Walk from the latest revision to the given one, keeping the last n+1 matching ones.
+			var (
+				newer  []Revision
+				stream = newFilteredRecentChangesStream(filter, "")
+			)
+			stream.until = before
+			next := stream.iterator()
+			for rev, done := next(); !done; rev, done = next() {
+				if len(newer) == n+1 {
+					newer = append(newer[:0], newer[1:]...)
+				}
+				newer = append(newer, rev)
+			}
+			if len(newer) > n {
+				return newer[1:], true, true
+			}
+			return newer, false, true
+		}
+		// The revision was not found, so show the first page.
+		after = ""
+	}
+
+	next := newFilteredRecentChangesStream(filter, after).iterator()
+	for rev, done := next(); !done; rev, done = next() {
+		if len(revs) == n {
+			hasOlder = true
+			break
+		}
+		revs = append(revs, rev)
+	}
+	slog.Info("Found recent changes", "n", len(revs))
+	return revs, after != "", hasOlder
+}
+
+// SameRevision tells whether the hashes are of the same revision. They might be abbreviated to different lengths. An empty hash is only the same as another empty hash.
+func SameRevision(a, b string) bool {
+	if a == "" || b == "" {
+		return a == b
+	}
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
+// Revisions returns slice of revisions for the given hypha name, ordered most recent first. If the hypha was renamed, the revisions it had under the previous names are there too, see Revision.HyphaName.
 func Revisions(hyphaName string) ([]Revision, error) {
-	revs, err := gitLog("--", hyphaName+".*")
-	slog.Info("Found revisions", "hyphaName", hyphaName, "n", len(revs), "err", err)
-	return revs, err
+	return revisionsFollowingRenames(hyphaName, false)
+}
+
+// revisionsFollowingRenames is Revisions that also returns the revisions of the subhyphae if subhyphae is true. Only the renames of the hypha itself are followed.
+func revisionsFollowingRenames(hyphaName string, subhyphae bool) ([]Revision, error) {
+	var (
+		result []Revision
+		name   = hyphaName
+		from   string
+	)
+	// Every round finds the revisions made under one name, up to the rename to it.
+	for renames := 0; ; renames++ {
+		paths := []string{name + ".*"}
+		if subhyphae {
+			paths = append(paths, name+"/*")
+		}
+		revs, err := store.Log(logQuery{From: from, Paths: paths})
+		if err != nil {
+			slog.Info("Found revisions", "hyphaName", hyphaName, "n", len(result), "err", err)
+			return result, err
+		}
+		var oldName string
+		for i := range revs {
+			revs[i].HyphaName = name
+			if match := renameMsgPattern.FindStringSubmatch(revs[i].Message); match != nil && renames < maxRenamesFollowed {
+				if before, found := nameBeforeRename(name, match[1], match[2]); found && before != name {
+					oldName, from = before, revs[i].Hash+"~"
+					revs = revs[:i+1]
+					break
+				}
+			}
+		}
+		result = append(result, revs...)
+		if oldName == "" {
+			break
+		}
+		name = oldName
+	}
+	slog.Info("Found revisions", "hyphaName", hyphaName, "n", len(result))
+	return result, nil
+}
+
+// Trailers of the revisions that deleted hyphae, see Op.WithTrailer. CategoryTrailer is there for every category the hypha was in. CategoryCountTrailer says how many there were. Hyphae deleted by older versions have neither, so their categories are not known.
+const (
+	CategoryTrailer      = "Category"
+	CategoryCountTrailer = "Categories"
+)
+
+// Deletion is a revision that deleted a hypha.
+type Deletion struct {
+	Revision
+	HyphaName string
+	// CategoriesKnown is false if the categories of the hypha were not saved when it was deleted.
+	CategoriesKnown bool
+}
+
+// Deletions returns the revisions that deleted hyphae, most recent first. A hypha might have been deleted several times.
+func Deletions() ([]Deletion, error) {
+	revs, err := store.Log(logQuery{Grep: "^Delete ‘"})
+	if err != nil {
+		return nil, err
+	}
+	// The messages of revisions are not read whole by Log, so the trailer is looked for separately.
+	withCategories, err := store.Log(logQuery{Grep: "^" + CategoryCountTrailer + ": "})
+	if err != nil {
+		return nil, err
+	}
+	categoriesKnown := make(map[string]bool)
+	for _, rev := range withCategories {
+		categoriesKnown[rev.Hash] = true
+	}
+	var deletions []Deletion
+	for _, rev := range revs {
+		rest, ok := strings.CutPrefix(rev.Message, "Delete ‘")
+		if !ok {
+			continue
+		}
+		if hyphaName, _, ok := strings.Cut(rest, "’"); ok {
+			deletions = append(deletions, Deletion{Revision: rev, HyphaName: hyphaName, CategoriesKnown: categoriesKnown[rev.Hash]})
+		}
+	}
+	return deletions, nil
+}
+
+// TrailerValues returns the values of the trailers with the key in the message of the commit with the given hash, see Op.WithTrailer.
+func TrailerValues(hash, key string) ([]string, error) {
+	message, err := store.Message(hash)
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, line := range strings.Split(message, "\n") {
+		if value, ok := strings.CutPrefix(line, key+": "); ok {
+			values = append(values, strings.TrimSpace(value))
+		}
+	}
+	return values, nil
+}
+
+// LastRevisionOf returns the short hash of the last commit that changed the file with the given path. If the file was never committed, the hash is empty.
+func LastRevisionOf(filepath string) (string, error) {
+	revs, err := store.Log(logQuery{Paths: []string{util.ShorterPath(filepath)}, MaxCount: 1})
+	if err != nil || len(revs) == 0 {
+		return "", err
+	}
+	return revs[0].Hash, nil
 }
 
 // FileChanged tells you if the file has been changed since the last commit.
 func FileChanged(path string) bool {
-	_, err := gitsh("diff", "--exit-code", path)
-	return err != nil
+	return store.Changed(path)
 }
 
 // Return time like dd — 13:42
@@ -266,13 +441,12 @@ func (rev *Revision) filesAffected() (filenames []string) {
 	if nil != rev.filesAffectedBuf {
 		return rev.filesAffectedBuf
 	}
-	// List of files affected by this revision, one per line.
-	out, err := silentGitsh("diff-tree", "--no-commit-id", "--name-only", "-r", rev.Hash)
+	filenames, err := store.FilesChanged(rev.Hash)
 	// There's an error? Well, whatever, let's just assign an empty slice, who cares.
 	if err != nil {
 		rev.filesAffectedBuf = []string{}
 	} else {
-		rev.filesAffectedBuf = strings.Split(out.String(), "\n")
+		rev.filesAffectedBuf = filenames
 	}
 	return rev.filesAffectedBuf
 }
@@ -358,20 +532,32 @@ func (rev *Revision) bestLink() string {
 
 // FileAtRevision shows how the file with the given file path looked at the commit with the hash. It may return an error if git fails.
 func FileAtRevision(filepath, hash string) (string, error) {
-	out, err := gitsh("show", hash+":"+strings.TrimPrefix(filepath, files.HyphaeDir()+"/"))
+	return store.ReadFile(filepath, hash)
+}
+
+// HyphaFilesAtRevision returns the paths of the files the hypha had at the commit with the given hash: the text file, the media file or both. A hypha that did not exist then has no files.
+func HyphaFilesAtRevision(hyphaName, hash string) ([]string, error) {
+	dir := path.Dir(hyphaName)
+	if dir == "." {
+		dir = ""
+	}
+	paths, err := store.ListFiles(dir, hash)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return out.String(), err
+	var result []string
+	for _, filePath := range paths {
+		ext := path.Ext(filePath)
+		if ext != "" && util.CanonicalName(strings.TrimSuffix(filePath, ext)) == hyphaName {
+			result = append(result, filepath.Join(files.HyphaeDir(), filePath))
+		}
+	}
+	return result, nil
 }
 
 // PrimitiveDiffAtRevision generates a plain-text diff for the given filepath at the commit with the given hash. It may return an error if git fails.
 func PrimitiveDiffAtRevision(filepath, hash string) (string, error) {
-	out, err := silentGitsh("diff", "--unified=1", "--no-color", hash+"~", hash, "--", filepath)
-	if err != nil {
-		return "", err
-	}
-	return out.String(), err
+	return store.Diff(filepath, hash)
 }
 
 // SplitPrimitiveDiff splits a primitive diff of a single file into hunks.
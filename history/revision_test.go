@@ -0,0 +1,26 @@
+package history
+
+import "testing"
+
+func TestSameRevision(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"", "", true},
+		{"abc1234", "", false},
+		{"", "abc1234", false},
+		{"abc1234", "abc1234", true},
+		{"abc1234", "abc1234def", true},
+		{"abc1234def", "abc1234", true},
+		{"abc1234", "abc1235", false},
+		{"none", "none", true},
+		{"none", "abc1234", false},
+	}
+
+	for _, tt := range tests {
+		if got := SameRevision(tt.a, tt.b); got != tt.want {
+			t.Errorf("SameRevision(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
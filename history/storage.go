@@ -0,0 +1,98 @@
+package history
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+
+	"github.com/bouncepaw/mycorrhiza/internal/cfg"
+)
+
+// storage keeps the history of the hyphae directory in a Git repository. Paths are relative to the hyphae directory or rooted inside it. Revisions are anything Git understands: hashes, short hashes, HEAD, hash~ and so on.
+//
+// Writing methods are called with gitMutex locked, reading methods might be called concurrently.
+type storage interface {
+	// Init makes a repository in the hyphae directory if there is none.
+	Init() error
+
+	// Log returns the revisions that match the query, most recent first. If nothing is committed yet, no revisions are returned.
+	Log(q logQuery) ([]Revision, error)
+	// Message returns the whole message of the commit.
+	Message(revHash string) (string, error)
+	// FilesChanged returns the paths of the files changed in the commit.
+	FilesChanged(revHash string) ([]string, error)
+	// ReadFile returns the contents the file had at the revision.
+	ReadFile(path, revHash string) (string, error)
+	// ListFiles returns the paths of the files in the directory at the revision. The subdirectories are not listed. The root directory is "".
+	ListFiles(dir, revHash string) ([]string, error)
+	// Diff returns the difference of the file between the commit and its parent in the unified format with one line of context.
+	Diff(path, revHash string) (string, error)
+	// Blame returns the lines of the file at the revision, each with the revision that last changed it. It also returns the numbers the lines had in those revisions.
+	Blame(path, revHash string) (lines []BlameLine, origNumbers []int, err error)
+	// Changed tells whether the file was changed since it was last added.
+	Changed(path string) bool
+
+	// Add stages the files.
+	Add(paths ...string) error
+	// Remove deletes the files and stages their deletion.
+	Remove(paths ...string) error
+	// Move renames the file and stages that. If there is a file at the destination already, it is replaced.
+	Move(from, to string) error
+	// Commit commits the staged changes.
+	Commit(authorName, authorEmail, message string) error
+}
+
+// logQuery selects revisions for storage.Log. The zero query selects all revisions.
+type logQuery struct {
+	// From is the revision to start from. If empty, HEAD is used.
+	From string
+	// Until is the revision to stop at, it is not selected itself. It is like Until..From in Git.
+	Until string
+	// Paths are glob patterns like in Git pathspecs, * matches slashes too. If set, only the revisions that changed matching files are selected.
+	Paths []string
+	// Grep is a regular expression. If set, only the revisions which have a line in their message matching it are selected.
+	Grep     string
+	Skip     int
+	MaxCount int
+	// Files makes the revisions come with the files they changed, see Revision.filesAffected. It saves asking for them one revision at a time. All the files are there, not only the ones matching Paths.
+	Files bool
+}
+
+// Git backends, see cfg.GitBackend. With backendAuto, the git executable is used if it is installed.
+const (
+	backendAuto    = "auto"
+	backendExec    = "exec"
+	backendBuiltin = "builtin"
+)
+
+// store is the storage used by the package. Set in Start.
+var store storage
+
+// Start chooses the Git backend. It fails if the exec backend is configured but the git executable is not found.
+func Start() error {
+	switch cfg.GitBackend {
+	case backendBuiltin:
+		slog.Info("Using the builtin Git backend")
+		store = &builtinStorage{}
+		return nil
+	case backendAuto, backendExec:
+	default:
+		err := fmt.Errorf("unknown Git backend %q, expected %s, %s or %s", cfg.GitBackend, backendAuto, backendExec, backendBuiltin)
+		slog.Error("Failed to start the history", "err", err)
+		return err
+	}
+
+	path, err := exec.LookPath("git")
+	if err != nil && cfg.GitBackend == backendExec {
+		slog.Error("Could not find the Git executable. Check your $PATH or set GitBackend to builtin or auto in the config", "err", err)
+		return err
+	}
+	if err != nil {
+		slog.Warn("Could not find the Git executable, using the builtin Git backend. Check your $PATH.", "err", err)
+		store = &builtinStorage{}
+		return nil
+	}
+	gitpath = path
+	store = execStorage{}
+	return nil
+}
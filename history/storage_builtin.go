@@ -0,0 +1,439 @@
+package history
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bouncepaw/mycorrhiza/internal/diff"
+	"github.com/bouncepaw/mycorrhiza/internal/files"
+	"github.com/bouncepaw/mycorrhiza/util"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// builtinStorage works with the repository in-process, the git executable is not needed. Reads share the repository, writes take it for themselves.
+type builtinStorage struct {
+	mu   sync.RWMutex
+	repo *git.Repository
+}
+
+func (s *builtinStorage) Init() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	repo, err := git.PlainOpen(files.HyphaeDir())
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		slog.Info("Initializing Git repo", "path", files.HyphaeDir())
+		repo, err = git.PlainInit(files.HyphaeDir(), false)
+	}
+	if err != nil {
+		return err
+	}
+	s.repo = repo
+	return nil
+}
+
+// commitAt returns the commit the revision points to. Call it with s.mu locked.
+func (s *builtinStorage) commitAt(revHash string) (*object.Commit, error) {
+	hash, err := s.repo.ResolveRevision(plumbing.Revision(revHash))
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.CommitObject(*hash)
+}
+
+func (s *builtinStorage) Log(q logQuery) ([]Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	from := q.From
+	if from == "" {
+		from = "HEAD"
+	}
+	hash, err := s.repo.ResolveRevision(plumbing.Revision(from))
+	if q.From == "" && errors.Is(err, plumbing.ErrReferenceNotFound) {
+		// Nothing is committed yet
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var until *plumbing.Hash
+	if q.Until != "" {
+		if until, err = s.repo.ResolveRevision(plumbing.Revision(q.Until)); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := &git.LogOptions{From: *hash, Order: git.LogOrderCommitterTime}
+	var grep *regexp.Regexp
+	if q.Grep != "" {
+		if grep, err = regexp.Compile("(?m)" + q.Grep); err != nil {
+			return nil, err
+		}
+	}
+	matchesPaths := func(filePath string) bool {
+		return slices.ContainsFunc(q.Paths, func(pattern string) bool {
+			return matchPathspec(pattern, filePath)
+		})
+	}
+
+	commits, err := s.repo.Log(opts)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		revs    []Revision
+		skipped int
+	)
+	err = commits.ForEach(func(c *object.Commit) error {
+		switch {
+		case until != nil && c.Hash == *until:
+			// The history of a wiki is linear, so everything older is reachable from until too.
+			return storer.ErrStop
+		case c.NumParents() > 1:
+			return nil
+		case grep != nil && !grep.MatchString(c.Message):
+			return nil
+		}
+		// The paths are not filtered by LogOptions.PathFilter, because it hides the commit Until points to.
+		var changed []string
+		if len(q.Paths) > 0 || q.Files {
+			if changed, err = filesChanged(c); err != nil {
+				return err
+			}
+		}
+		if len(q.Paths) > 0 && !slices.ContainsFunc(changed, matchesPaths) {
+			return nil
+		}
+		if skipped < q.Skip {
+			skipped++
+			return nil
+		}
+		rev := revisionOf(c)
+		if q.Files {
+			rev.filesAffectedBuf = changed
+		}
+		revs = append(revs, rev)
+		if q.MaxCount > 0 && len(revs) >= q.MaxCount {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	return revs, err
+}
+
+// matchPathspec tells whether the path matches the glob pattern the way Git pathspecs do, that is, * and ? match slashes too.
+func matchPathspec(pattern, filePath string) bool {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	matched, _ := regexp.MatchString("^"+expr+"$", filePath)
+	return matched
+}
+
+// revisionOf makes a revision like parseRevisionLine does.
+func revisionOf(c *object.Commit) Revision {
+	var (
+		username, _, _ = strings.Cut(c.Author.Email, "@")
+		subject, _, _  = strings.Cut(c.Message, "\n")
+	)
+	return Revision{
+		Hash:     c.Hash.String()[:7],
+		Username: username,
+		Time:     time.Unix(c.Author.When.Unix(), 0),
+		Message:  subject,
+	}
+}
+
+func (s *builtinStorage) Message(revHash string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, err := s.commitAt(revHash)
+	if err != nil {
+		return "", err
+	}
+	return c.Message, nil
+}
+
+func (s *builtinStorage) FilesChanged(revHash string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, err := s.commitAt(revHash)
+	if err != nil {
+		return nil, err
+	}
+	return filesChanged(c)
+}
+
+// filesChanged returns the paths of the files changed in the commit.
+func filesChanged(c *object.Commit) ([]string, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var parentTree *object.Tree
+	if c.NumParents() > 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	for _, change := range changes {
+		// Renamed files are a deletion and an addition here, like in git diff-tree.
+		if change.From.Name != "" {
+			paths = append(paths, change.From.Name)
+		}
+		if change.To.Name != "" && change.To.Name != change.From.Name {
+			paths = append(paths, change.To.Name)
+		}
+	}
+	return paths, nil
+}
+
+func (s *builtinStorage) ReadFile(filePath, revHash string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readFile(filePath, revHash)
+}
+
+// readFile is ReadFile with s.mu locked.
+func (s *builtinStorage) readFile(filePath, revHash string) (string, error) {
+	c, err := s.commitAt(revHash)
+	if err != nil {
+		return "", err
+	}
+	file, err := c.File(util.ShorterPath(filePath))
+	if err != nil {
+		return "", err
+	}
+	return file.Contents()
+}
+
+func (s *builtinStorage) ListFiles(dir, revHash string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, err := s.commitAt(revHash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	if dir != "" {
+		if tree, err = tree.Tree(dir); errors.Is(err, object.ErrDirectoryNotFound) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	var result []string
+	for _, entry := range tree.Entries {
+		if entry.Mode.IsFile() {
+			result = append(result, path.Join(dir, entry.Name))
+		}
+	}
+	return result, nil
+}
+
+func (s *builtinStorage) Diff(filePath, revHash string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	filePath = util.ShorterPath(filePath)
+	to, err := s.readFile(filePath, revHash)
+	if err != nil && !errors.Is(err, object.ErrFileNotFound) {
+		return "", err
+	}
+	// The file might be new, or the commit might be the first one.
+	from, _ := s.readFile(filePath, revHash+"~")
+	return diff.Unified("a/"+filePath, "b/"+filePath, from, to, 1), nil
+}
+
+func (s *builtinStorage) Blame(filePath, revHash string) ([]BlameLine, []int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	filePath = util.ShorterPath(filePath)
+	text, err := s.readFile(filePath, revHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	var (
+		textLines = diff.Lines(text)
+		lines     = make([]BlameLine, len(textLines))
+		// numbers are the numbers the lines have in the version being looked at. 0 means the line is blamed already.
+		numbers = make([]int, len(textLines))
+		// pending is how many lines are not blamed yet.
+		pending = len(textLines)
+	)
+	for i, line := range textLines {
+		lines[i] = BlameLine{Number: i + 1, Text: line}
+		numbers[i] = i + 1
+	}
+	origNumbers := make([]int, len(textLines))
+
+	// Go through the commits that changed the file, newest first. The lines that the commit inserted are blamed on it, the rest are looked for in its parent.
+	c, err := s.commitAt(revHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	commits, err := s.repo.Log(&git.LogOptions{From: c.Hash, FileName: &filePath})
+	if err != nil {
+		return nil, nil, err
+	}
+	err = commits.ForEach(func(c *object.Commit) error {
+		current, err := fileContents(c, filePath)
+		if err != nil {
+			return err
+		}
+		var previous string
+		if parent, err := c.Parent(0); err == nil {
+			previous, _ = fileContents(parent, filePath)
+		}
+
+		// Map the line numbers of this version to the ones of the parent version.
+		var (
+			toParent       = map[int]int{}
+			oldNum, newNum = 1, 1
+		)
+		for _, e := range diff.Diff(diff.Lines(previous), diff.Lines(current)) {
+			switch e.Kind {
+			case diff.Equal:
+				toParent[newNum] = oldNum
+				oldNum, newNum = oldNum+1, newNum+1
+			case diff.Delete:
+				oldNum++
+			case diff.Insert:
+				newNum++
+			}
+		}
+		for i, n := range numbers {
+			if n == 0 {
+				continue
+			}
+			if parentNum, ok := toParent[n]; ok {
+				numbers[i] = parentNum
+				continue
+			}
+			lines[i].Revision = revisionOf(c)
+			origNumbers[i] = n
+			numbers[i] = 0
+			pending--
+		}
+		if pending == 0 {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	return lines, origNumbers, err
+}
+
+// fileContents returns the contents of the file in the commit. If there is no such file, the contents are empty.
+func fileContents(c *object.Commit, filePath string) (string, error) {
+	file, err := c.File(filePath)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return file.Contents()
+}
+
+func (s *builtinStorage) Changed(filePath string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	// Like git diff, compare the file with the index only. Untracked files are not changed.
+	idx, err := s.repo.Storer.Index()
+	if err != nil {
+		return false
+	}
+	name := filepath.ToSlash(util.ShorterPath(filePath))
+	entry, err := idx.Entry(name)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join(files.HyphaeDir(), filepath.FromSlash(name)))
+	if err != nil {
+		return true // The file was deleted.
+	}
+	return plumbing.ComputeHash(plumbing.BlobObject, data) != entry.Hash
+}
+
+func (s *builtinStorage) Add(paths ...string) error {
+	return s.withWorktree(func(worktree *git.Worktree) error {
+		for _, filePath := range paths {
+			if _, err := worktree.Add(util.ShorterPath(filePath)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *builtinStorage) Remove(paths ...string) error {
+	return s.withWorktree(func(worktree *git.Worktree) error {
+		for _, filePath := range paths {
+			if _, err := worktree.Remove(util.ShorterPath(filePath)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *builtinStorage) Move(from, to string) error {
+	from, to = util.ShorterPath(from), util.ShorterPath(to)
+	return s.withWorktree(func(worktree *git.Worktree) error {
+		if _, err := os.Stat(filepath.Join(files.HyphaeDir(), to)); err == nil {
+			// Like git mv --force
+			if _, err := worktree.Remove(to); err != nil {
+				if err := os.Remove(filepath.Join(files.HyphaeDir(), to)); err != nil {
+					return err
+				}
+			}
+		}
+		_, err := worktree.Move(from, to)
+		return err
+	})
+}
+
+func (s *builtinStorage) Commit(authorName, authorEmail, message string) error {
+	return s.withWorktree(func(worktree *git.Worktree) error {
+		now := time.Now()
+		_, err := worktree.Commit(message, &git.CommitOptions{
+			Author:    &object.Signature{Name: authorName, Email: authorEmail, When: now},
+			Committer: &object.Signature{Name: "wikimind", Email: "wikimind@mycorrhiza", When: now},
+		})
+		return err
+	})
+}
+
+// withWorktree calls f with the worktree of the repository, which only f uses meanwhile.
+func (s *builtinStorage) withWorktree(f func(*git.Worktree) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	worktree, err := s.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return f(worktree)
+}
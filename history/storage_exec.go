@@ -0,0 +1,196 @@
+package history
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bouncepaw/mycorrhiza/internal/files"
+	"github.com/bouncepaw/mycorrhiza/util"
+)
+
+// execStorage runs the git executable for everything.
+type execStorage struct{}
+
+func (execStorage) Init() error {
+	// Detect if the Git repo directory is a Git repository
+	isGitRepo := true
+	buf, err := silentGitsh("rev-parse", "--git-dir")
+	if err != nil {
+		isGitRepo = false
+	}
+	if isGitRepo {
+		gitDir := buf.String()
+		if filepath.IsAbs(gitDir) && !filepath.HasPrefix(gitDir, files.HyphaeDir()) {
+			isGitRepo = false
+		}
+	}
+	if isGitRepo {
+		return nil
+	}
+	slog.Info("Initializing Git repo", "path", files.HyphaeDir())
+	if _, err := gitsh("init"); err != nil {
+		return err
+	}
+	_, err = gitsh("config", "core.quotePath", "false")
+	return err
+}
+
+func (execStorage) Log(q logQuery) ([]Revision, error) {
+	format := "%h\t%ae\t%at\t%s"
+	if q.Files {
+		// Every revision starts with a record separator then, and its files follow on separate lines.
+		format = "%x1e" + format
+	}
+	args := []string{
+		"log", "--abbrev-commit", "--no-merges",
+		"--pretty=format:" + format,
+	}
+	if q.Files {
+		args = append(args, "--name-only", "--full-diff")
+	}
+	if q.Grep != "" {
+		args = append(args, "--grep="+q.Grep)
+	}
+	if q.Skip > 0 {
+		args = append(args, "--skip="+strconv.Itoa(q.Skip))
+	}
+	if q.MaxCount > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(q.MaxCount))
+	}
+	switch {
+	case q.Until != "" && q.From != "":
+		args = append(args, q.Until+".."+q.From)
+	case q.Until != "":
+		args = append(args, q.Until+"..HEAD")
+	case q.From != "":
+		args = append(args, q.From)
+	}
+	args = append(args, "--")
+	args = append(args, q.Paths...)
+
+	out, err := silentGitsh(args...)
+	if strings.Contains(out.String(), "bad revision 'HEAD'") || strings.Contains(out.String(), "does not have any commits yet") {
+		// Then we have no recent changes! It's a hack.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	outStr := out.String()
+	if outStr == "" {
+		// if there are no commits to return
+		return nil, nil
+	}
+
+	var revs []Revision
+	if q.Files {
+		for _, record := range strings.Split(outStr, "\x1e")[1:] {
+			lines := strings.Split(strings.TrimRight(record, "\n"), "\n")
+			rev := parseRevisionLine(lines[0])
+			rev.filesAffectedBuf = []string{}
+			for _, filePath := range lines[1:] {
+				if filePath != "" {
+					rev.filesAffectedBuf = append(rev.filesAffectedBuf, filePath)
+				}
+			}
+			revs = append(revs, rev)
+		}
+		return revs, nil
+	}
+	for _, line := range strings.Split(outStr, "\n") {
+		revs = append(revs, parseRevisionLine(line))
+	}
+	return revs, nil
+}
+
+func (execStorage) Message(revHash string) (string, error) {
+	out, err := silentGitsh("show", "--no-patch", "--format=%B", revHash)
+	return out.String(), err
+}
+
+func (execStorage) FilesChanged(revHash string) ([]string, error) {
+	// List of files affected by this revision, one per line.
+	out, err := silentGitsh("diff-tree", "--no-commit-id", "--name-only", "-r", revHash)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(out.String(), "\n"), nil
+}
+
+func (execStorage) ReadFile(path, revHash string) (string, error) {
+	out, err := gitsh("show", revHash+":"+util.ShorterPath(path))
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func (execStorage) ListFiles(dir, revHash string) ([]string, error) {
+	args := []string{"ls-tree", "-z", revHash}
+	if dir != "" {
+		args = append(args, "--", dir+"/")
+	}
+	out, err := silentGitsh(args...)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, entry := range strings.Split(out.String(), "\x00") {
+		// Entries look like <mode> <type> <object>\t<path>
+		info, filePath, found := strings.Cut(entry, "\t")
+		if found && !strings.Contains(info, " tree ") {
+			result = append(result, filePath)
+		}
+	}
+	return result, nil
+}
+
+func (execStorage) Diff(path, revHash string) (string, error) {
+	out, err := silentGitsh("diff", "--unified=1", "--no-color", revHash+"~", revHash, "--", path)
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func (execStorage) Blame(path, revHash string) ([]BlameLine, []int, error) {
+	out, err := silentGitsh("blame", "--porcelain", revHash, "--", util.ShorterPath(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	lines, origNumbers := parseBlame(out.String())
+	return lines, origNumbers, nil
+}
+
+func (execStorage) Changed(path string) bool {
+	_, err := gitsh("diff", "--exit-code", path)
+	return err != nil
+}
+
+func (execStorage) Add(paths ...string) error {
+	_, err := gitsh(append([]string{"add"}, paths...)...)
+	return err
+}
+
+func (execStorage) Remove(paths ...string) error {
+	_, err := gitsh(append([]string{"rm", "--quiet", "--"}, paths...)...)
+	return err
+}
+
+func (execStorage) Move(from, to string) error {
+	_, err := gitsh("mv", "--force", from, to)
+	return err
+}
+
+func (execStorage) Commit(authorName, authorEmail, message string) error {
+	_, err := gitsh(
+		"commit",
+		"--author='"+authorName+" <"+authorEmail+">'",
+		"--message="+message,
+		"--no-gpg-sign",
+	)
+	return err
+}
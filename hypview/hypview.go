@@ -21,6 +21,7 @@ var (
 {{define "rename recursively"}}Также переименовать подгифы{{end}}
 {{define "rename tip"}}Переименовывайте аккуратно. <a href="/help/en/rename">Документация на английском.</a>{{end}}
 {{define "leave redirection"}}Оставить перенаправление{{end}}
+{{define "update links"}}Обновить ссылки в других гифах{{end}}
 
 
 `
@@ -44,7 +44,7 @@ var backlinkIndex = make(map[string]linkSet)
 func IndexBacklinks() {
 	// It is safe to ignore the mutex, because there is only one worker.
 	for h := range hyphae.FilterHyphaeWithText(hyphae.YieldExistingHyphae()) {
-		foundLinks := extractHyphaLinksFromContent(h.CanonicalName(), fetchText(h))
+		foundLinks := extractHyphaLinks(h)
 		for _, link := range foundLinks {
 			if _, exists := backlinkIndex[link]; !exists {
 				backlinkIndex[link] = make(linkSet)
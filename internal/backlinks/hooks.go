@@ -2,6 +2,7 @@ package backlinks
 
 import (
 	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/mdrenderer"
 	"github.com/bouncepaw/mycorrhiza/mycoopts"
 
 	"git.sr.ht/~bouncepaw/mycomarkup/v5"
@@ -12,14 +13,21 @@ import (
 
 // UpdateBacklinksAfterEdit is a creation/editing hook for backlinks index
 func UpdateBacklinksAfterEdit(h hyphae.Hypha, oldText string) {
-	oldLinks := extractHyphaLinksFromContent(h.CanonicalName(), oldText)
+	oldLinks := extractHyphaLinksFromContent(h.CanonicalName(), textFormat(h), oldText)
+	newLinks := extractHyphaLinks(h)
+	backlinkConveyor <- backlinkIndexEdit{h.CanonicalName(), oldLinks, newLinks}
+}
+
+// UpdateBacklinksAfterConvert is a format conversion hook for backlinks index. The old text is in the old format.
+func UpdateBacklinksAfterConvert(h hyphae.Hypha, oldText string, oldFormat hyphae.TextFormat) {
+	oldLinks := extractHyphaLinksFromContent(h.CanonicalName(), oldFormat, oldText)
 	newLinks := extractHyphaLinks(h)
 	backlinkConveyor <- backlinkIndexEdit{h.CanonicalName(), oldLinks, newLinks}
 }
 
 // UpdateBacklinksAfterDelete is a deletion hook for backlinks index
 func UpdateBacklinksAfterDelete(h hyphae.Hypha, oldText string) {
-	oldLinks := extractHyphaLinksFromContent(h.CanonicalName(), oldText)
+	oldLinks := extractHyphaLinksFromContent(h.CanonicalName(), textFormat(h), oldText)
 	backlinkConveyor <- backlinkIndexDeletion{h.CanonicalName(), oldLinks}
 }
 
@@ -31,11 +39,22 @@ func UpdateBacklinksAfterRename(h hyphae.Hypha, oldName string) {
 
 // extractHyphaLinks extracts hypha links from a desired hypha
 func extractHyphaLinks(h hyphae.Hypha) []string {
-	return extractHyphaLinksFromContent(h.CanonicalName(), fetchText(h))
+	return extractHyphaLinksFromContent(h.CanonicalName(), textFormat(h), fetchText(h))
 }
 
-// extractHyphaLinksFromContent extracts local hypha links from the provided text.
-func extractHyphaLinksFromContent(hyphaName string, contents string) []string {
+// textFormat returns the markup format of the hypha's text part.
+func textFormat(h hyphae.Hypha) hyphae.TextFormat {
+	if h, ok := h.(hyphae.ExistingHypha); ok && h.HasTextFile() {
+		return hyphae.DetectTextFormat(h.TextFilePath())
+	}
+	return hyphae.FormatMycomarkup
+}
+
+// extractHyphaLinksFromContent extracts local hypha links from the provided text written in the given format.
+func extractHyphaLinksFromContent(hyphaName string, format hyphae.TextFormat, contents string) []string {
+	if format == hyphae.FormatMarkdown {
+		return mdrenderer.ExtractHyphaLinks(hyphaName, []byte(contents))
+	}
 	ctx, _ := mycocontext.ContextFromStringInput(contents, mycoopts.MarkupOptions(hyphaName))
 	linkVisitor, getLinks := tools.LinkVisitor(ctx)
 	// Ignore the result of BlockTree because we call it for linkVisitor.
@@ -0,0 +1,98 @@
+package backlinks
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/mdrenderer"
+	"github.com/bouncepaw/mycorrhiza/mycoopts"
+	"github.com/bouncepaw/mycorrhiza/util"
+
+	"git.sr.ht/~bouncepaw/mycomarkup/v5/links"
+	"git.sr.ht/~bouncepaw/mycomarkup/v5/mycocontext"
+)
+
+var (
+	mycoLinkTargetRe         = regexp.MustCompile(`\[\[([^\[\]|\n]+)(?:\|[^\[\]\n]*)?\]\]`)
+	mycoRocketTargetRe       = regexp.MustCompile(`(?m)^=>[ \t]*([^\s|]+)`)
+	mycoTransclusionTargetRe = regexp.MustCompile(`(?m)^<=([^|\n]+)`)
+	mycoMonospaceRe          = regexp.MustCompile("`[^`\n]*`")
+)
+
+// RewriteLinks returns the text of the hypha with local links to the renamed hyphae pointing to their new names. The map is from old canonical names to new ones. The hypha itself might have been renamed from hyphaName to newHyphaName; relative links that still work after that are left as they are.
+func RewriteLinks(hyphaName, newHyphaName string, format hyphae.TextFormat, text string, renamed map[string]string) string {
+	if format == hyphae.FormatMarkdown {
+		return string(mdrenderer.RewriteHyphaLinks(hyphaName, newHyphaName, []byte(text), renamed))
+	}
+
+	var (
+		source = []byte(text)
+		oldCtx = linkContext(hyphaName)
+		newCtx = linkContext(newHyphaName)
+		code   = mycoCodeRanges(text)
+		edits  []util.TextEdit
+	)
+	rewrite := func(target string) (string, bool) {
+		name, ok := localLinkTarget(oldCtx, target)
+		if !ok {
+			return "", false
+		}
+		newName, ok := renamed[name]
+		if !ok {
+			return "", false
+		}
+		if stillName, _ := localLinkTarget(newCtx, target); stillName == newName {
+			return "", false
+		}
+		if _, anchor, found := strings.Cut(target, "#"); found {
+			newName += "#" + anchor
+		}
+		return newName, true
+	}
+	for _, re := range []*regexp.Regexp{mycoLinkTargetRe, mycoRocketTargetRe, mycoTransclusionTargetRe} {
+		edits = append(edits, util.SubmatchEdits(source, re, code, rewrite)...)
+	}
+	return string(util.ApplyTextEdits(source, edits))
+}
+
+func linkContext(hyphaName string) mycocontext.Context {
+	ctx, _ := mycocontext.ContextFromStringInput("", mycoopts.MarkupOptions(hyphaName))
+	return ctx
+}
+
+// localLinkTarget returns the canonical name of the hypha the Mycomarkup link target points to, if it is a local link.
+func localLinkTarget(ctx mycocontext.Context, target string) (string, bool) {
+	link, ok := links.LinkFrom(ctx, target, "").(*links.LocalLink)
+	if !ok {
+		return "", false
+	}
+	return link.Target(ctx), true
+}
+
+// mycoCodeRanges returns the byte ranges of codeblocks and monospace spans in Mycomarkup text. Link syntax is not a link there.
+func mycoCodeRanges(text string) (ranges [][2]int) {
+	var (
+		pos         int
+		inCodeblock bool
+		blockStart  int
+	)
+	for _, line := range strings.SplitAfter(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "```") && !inCodeblock:
+			inCodeblock, blockStart = true, pos
+		case strings.HasPrefix(line, "```"):
+			inCodeblock = false
+			ranges = append(ranges, [2]int{blockStart, pos + len(line)})
+		case !inCodeblock:
+			for _, span := range mycoMonospaceRe.FindAllStringIndex(line, -1) {
+				ranges = append(ranges, [2]int{pos + span[0], pos + span[1]})
+			}
+		}
+		pos += len(line)
+	}
+	if inCodeblock {
+		ranges = append(ranges, [2]int{blockStart, len(text)})
+	}
+	return ranges
+}
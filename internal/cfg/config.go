@@ -43,6 +43,8 @@ var (
 	TelegramEnabled  bool
 	TelegramBotToken string
 	TelegramBotName  string
+
+	GitBackend string
 )
 
 // WikiDir is a full path to the wiki storage directory, which also must be a
@@ -59,6 +61,7 @@ type Config struct {
 	Authorization
 	CustomScripts `comment:"You can specify additional scripts to load on different kinds of pages, delimited by a comma ',' sign."`
 	Telegram      `comment:"You can enable Telegram authorization. Follow these instructions: https://core.telegram.org/widgets/login#setting-up-a-bot"`
+	History
 }
 
 // Hyphae is a section of Config which has fields related to special hyphae.
@@ -105,6 +108,11 @@ type Telegram struct {
 	TelegramBotName  string `comment:"Username of your bot, sans @."`
 }
 
+// History is the section of Config that sets how the history is kept.
+type History struct {
+	GitBackend string `comment:"Either exec, which runs the git executable, builtin, which does not need Git installed, or auto, which is exec if Git is installed and builtin otherwise. Default: auto."`
+}
+
 // ReadConfigFile reads a config on the given path and stores the
 // configuration. Call it sometime during the initialization.
 func ReadConfigFile(path string) error {
@@ -138,6 +146,9 @@ func ReadConfigFile(path string) error {
 			TelegramBotToken: "",
 			TelegramBotName:  "",
 		},
+		History: History{
+			GitBackend: "auto",
+		},
 	}
 
 	f, err := ini.Load(path)
@@ -191,6 +202,7 @@ func ReadConfigFile(path string) error {
 	TelegramBotToken = cfg.TelegramBotToken
 	TelegramBotName = cfg.TelegramBotName
 	TelegramEnabled = (TelegramBotToken != "") && (TelegramBotName != "")
+	GitBackend = cfg.GitBackend
 
 	// This URL makes much more sense. If no URL is set or the protocol is forgotten, assume HTTP.
 	if URL == "" {
@@ -2,26 +2,50 @@ package converter
 
 import (
 	"fmt"
+	"html"
 	"strings"
 
 	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/mdrenderer"
 	"github.com/bouncepaw/mycorrhiza/mycoopts"
 
 	"git.sr.ht/~bouncepaw/mycomarkup/v5"
 	"git.sr.ht/~bouncepaw/mycomarkup/v5/blocks"
 	"git.sr.ht/~bouncepaw/mycomarkup/v5/mycocontext"
-	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
-	"github.com/yuin/goldmark/extension"
 	gast "github.com/yuin/goldmark/extension/ast"
 	"github.com/yuin/goldmark/text"
 )
 
+// warning is a converter warning about a top-level block of the source.
+type warning struct {
+	// block is the index of the block, kind is its kind, see mycoBlockKind and markdownBlockKind.
+	block   int
+	kind    string
+	message string
+}
+
+func warningMessages(warnings []warning) []string {
+	messages := []string{}
+	for _, w := range warnings {
+		messages = append(messages, w.message)
+	}
+	return messages
+}
+
 // MycomarkupToMarkdown converts Mycomarkup to Markdown using AST parsing
 // This is LOSSY - some features don't have Markdown equivalents
 func MycomarkupToMarkdown(content string) (string, []string) {
-	warnings := []string{}
-	var output strings.Builder
+	result, warnings := mycomarkupToMarkdown(content)
+	return result, warningMessages(warnings)
+}
+
+func mycomarkupToMarkdown(content string) (string, []warning) {
+	var (
+		messages []string
+		warnings []warning
+		output   strings.Builder
+	)
 
 	// Parse mycomarkup into AST
 	ctx, _ := mycocontext.ContextFromStringInput(content, mycoopts.MarkupOptions(""))
@@ -32,12 +56,43 @@ func MycomarkupToMarkdown(content string) (string, []string) {
 		if i > 0 {
 			output.WriteString("\n")
 		}
-		convertMycoBlockToMarkdown(block, &output, &warnings, 0)
+		convertMycoBlockToMarkdown(block, &output, &messages, 0)
+		for _, message := range messages[len(warnings):] {
+			warnings = append(warnings, warning{i, mycoBlockKind(block), message})
+		}
 	}
 
 	return output.String(), warnings
 }
 
+// mycoBlockKind names the kind of the block. The names are shared with markdownBlockKind for the blocks both formats have.
+func mycoBlockKind(block blocks.Block) string {
+	switch block.(type) {
+	case blocks.Heading:
+		return "heading"
+	case blocks.Paragraph:
+		return "paragraph"
+	case blocks.CodeBlock:
+		return "code block"
+	case blocks.List:
+		return "list"
+	case blocks.ThematicBreak:
+		return "thematic break"
+	case blocks.Quote:
+		return "quote"
+	case blocks.Table:
+		return "table"
+	case blocks.Img:
+		return "image"
+	case blocks.LaunchPad:
+		return "launchpad"
+	case blocks.Transclusion:
+		return "transclusion"
+	default:
+		return fmt.Sprintf("%T", block)
+	}
+}
+
 func convertMycoBlockToMarkdown(block blocks.Block, output *strings.Builder, warnings *[]string, depth int) {
 	switch b := block.(type) {
 	case blocks.Heading:
@@ -60,8 +115,10 @@ func convertMycoBlockToMarkdown(block blocks.Block, output *strings.Builder, war
 			output.WriteString(lang)
 		}
 		output.WriteString("\n")
-		output.WriteString(b.Contents())
-		if !strings.HasSuffix(b.Contents(), "\n") {
+		// The contents are escaped for HTML already.
+		contents := html.UnescapeString(b.Contents())
+		output.WriteString(contents)
+		if !strings.HasSuffix(contents, "\n") {
 			output.WriteString("\n")
 		}
 		output.WriteString("```\n")
@@ -88,7 +145,7 @@ func convertMycoBlockToMarkdown(block blocks.Block, output *strings.Builder, war
 		}
 
 	case blocks.Table:
-		convertTableToHTML(b, output, warnings)
+		convertTableToMarkdown(b, output, warnings)
 
 	case blocks.Img:
 		convertImgToMarkdown(b, output, warnings)
@@ -97,7 +154,7 @@ func convertMycoBlockToMarkdown(block blocks.Block, output *strings.Builder, war
 		convertLaunchPadToHTML(b, output, warnings)
 
 	case blocks.Transclusion:
-		convertTransclusionToHTML(b, output, warnings)
+		convertTransclusionToMarkdown(b, output, warnings)
 
 	default:
 		// Unknown block type, try to preserve as-is
@@ -126,7 +183,11 @@ func convertFormattedToMarkdown(formatted *blocks.Formatted, output *strings.Bui
 	// Track active styles
 	styleState := blocks.CleanStyleState()
 
-	for _, line := range formatted.Lines {
+	for i, line := range formatted.Lines {
+		if i > 0 {
+			// Every line of a Mycomarkup paragraph starts on a new line, so the breaks are hard ones.
+			output.WriteString("\\\n")
+		}
 		for _, span := range line {
 			switch s := span.(type) {
 			case blocks.SpanTableEntry:
@@ -229,6 +290,94 @@ func convertInlineLinkToMarkdown(link blocks.InlineLink, output *strings.Builder
 	output.WriteString(")")
 }
 
+// convertTableToMarkdown writes a GFM pipe table if the table can be expressed as such. Otherwise, it falls back to HTML and says why.
+func convertTableToMarkdown(table blocks.Table, output *strings.Builder, warnings *[]string) {
+	if reason := pipeTableObstacle(table); reason != "" {
+		name := "Table"
+		if caption := strings.TrimSpace(table.Caption()); caption != "" {
+			name = fmt.Sprintf("Table ‘%s’", caption)
+		}
+		*warnings = append(*warnings, fmt.Sprintf("%s was converted to HTML because %s", name, reason))
+		convertTableToHTML(table, output, warnings)
+		return
+	}
+
+	rows := table.Rows()
+	writePipeRow := func(cells []string) {
+		output.WriteString("|")
+		for _, cell := range cells {
+			output.WriteString(" " + cell + " |")
+		}
+		output.WriteString("\n")
+	}
+	for i, row := range rows {
+		var cells []string
+		for _, cell := range row.Cells() {
+			cells = append(cells, pipeTableCell(cell, warnings))
+		}
+		writePipeRow(cells)
+		if i == 0 {
+			delimiters := make([]string, len(cells))
+			for j := range delimiters {
+				delimiters[j] = "---"
+			}
+			writePipeRow(delimiters)
+		}
+	}
+}
+
+// pipeTableObstacle returns why the table cannot be a GFM pipe table, or an empty string if it can.
+func pipeTableObstacle(table blocks.Table) string {
+	rows := table.Rows()
+	switch {
+	case table.Caption() != "":
+		return "pipe tables have no captions"
+	case len(rows) == 0:
+		return "it has no rows"
+	case !rows[0].LooksLikeThead():
+		return "its first row is not a header row"
+	}
+
+	for i, row := range rows {
+		for j, cell := range row.Cells() {
+			if cell.Colspan() > 1 {
+				return fmt.Sprintf("cell %d of row %d spans %d columns", j+1, i+1, cell.Colspan())
+			}
+			if i > 0 && cell.IsHeaderCell() {
+				return fmt.Sprintf("cell %d of row %d is a header cell outside the first row", j+1, i+1)
+			}
+			contents := cell.Contents()
+			if len(contents) > 1 {
+				return fmt.Sprintf("cell %d of row %d has several blocks", j+1, i+1)
+			}
+			if len(contents) == 1 {
+				paragraph, ok := contents[0].(blocks.Paragraph)
+				if !ok {
+					return fmt.Sprintf("cell %d of row %d has a block that is not text", j+1, i+1)
+				}
+				if len(paragraph.Lines) > 1 {
+					return fmt.Sprintf("cell %d of row %d has several lines", j+1, i+1)
+				}
+			}
+		}
+		if len(row.Cells()) != len(rows[0].Cells()) {
+			return fmt.Sprintf("row %d has a different number of cells than the header row", i+1)
+		}
+	}
+	return ""
+}
+
+// pipeTableCell returns the cell's contents as inline Markdown, with pipes escaped.
+func pipeTableCell(cell blocks.TableCell, warnings *[]string) string {
+	var cellOutput strings.Builder
+	for _, block := range cell.Contents() {
+		if paragraph, ok := block.(blocks.Paragraph); ok {
+			convertFormattedToMarkdown(&paragraph.Formatted, &cellOutput, warnings)
+		}
+	}
+	return strings.ReplaceAll(strings.TrimSpace(cellOutput.String()), "|", "\\|")
+}
+
 func convertTableToHTML(table blocks.Table, output *strings.Builder, warnings *[]string) {
 	output.WriteString("<table>\n")
 
@@ -339,6 +488,7 @@ func convertImgToMarkdown(img blocks.Img, output *strings.Builder, warnings *[]s
 }
 
 func convertLaunchPadToHTML(lp blocks.LaunchPad, output *strings.Builder, warnings *[]string) {
+	*warnings = append(*warnings, "Rocket links were converted to HTML because Markdown has no launchpads")
 	ctx, _ := mycocontext.ContextFromStringInput("", mycoopts.MarkupOptions(""))
 
 	output.WriteString("<div class=\"launchpad\">\n")
@@ -359,34 +509,56 @@ func convertLaunchPadToHTML(lp blocks.LaunchPad, output *strings.Builder, warnin
 	output.WriteString("</div>\n")
 }
 
-func convertTransclusionToHTML(t blocks.Transclusion, output *strings.Builder, warnings *[]string) {
-	// Transclusions can't be converted - preserve as HTML comment
-	output.WriteString("<!-- Transclusion: ")
-	// We can't easily get the original syntax, so just note it
-	output.WriteString("transclusion not supported in Markdown")
-	output.WriteString(" -->\n")
+func convertTransclusionToMarkdown(t blocks.Transclusion, output *strings.Builder, warnings *[]string) {
+	// Markdown hyphae support the same <= syntax
+	if t.Target == "" {
+		*warnings = append(*warnings, "Transclusion without a target was dropped")
+		return
+	}
+	output.WriteString("<= ")
+	output.WriteString(t.Target)
+	var selector string
+	switch t.Selector {
+	case blocks.SelectorAttachment:
+		selector = "attachment"
+	case blocks.SelectorDescription:
+		selector = "description"
+	case blocks.SelectorText:
+		selector = "text"
+	case blocks.SelectorFull:
+		selector = "full"
+	}
+	if t.Blend {
+		selector = strings.TrimSpace(selector + " blend")
+	}
+	if selector != "" {
+		output.WriteString(" | ")
+		output.WriteString(selector)
+	}
+	output.WriteString("\n")
 }
 
 // MarkdownToMycomarkup converts Markdown to Mycomarkup using AST parsing
 func MarkdownToMycomarkup(content string) (string, []string) {
-	warnings := []string{}
-	var output strings.Builder
-
-	// Create goldmark parser with extensions
-	md := goldmark.New(
-		goldmark.WithExtensions(
-			extension.GFM,
-			extension.Table,
-			extension.Strikethrough,
-			extension.Linkify,
-			extension.TaskList,
-		),
+	result, warnings := markdownToMycomarkup(content)
+	return result, warningMessages(warnings)
+}
+
+func markdownToMycomarkup(content string) (string, []warning) {
+	var (
+		messages []string
+		warnings []warning
+		output   strings.Builder
+		// block is the index of the top-level block being converted, kind is its kind.
+		block = -1
+		kind  string
 	)
 
 	// Parse markdown
 	source := []byte(content)
 	reader := text.NewReader(source)
-	doc := md.Parser().Parse(reader)
+	// The same parser as for rendering, so that wiki-links and transclusions are recognized
+	doc := mdrenderer.Markdown.Parser().Parse(reader)
 
 	// Walk the AST
 	firstBlock := true
@@ -407,9 +579,13 @@ func MarkdownToMycomarkup(content string) (string, []string) {
 		}
 		if node.Type() == ast.TypeBlock && node.Parent().Kind() == ast.KindDocument {
 			firstBlock = false
+			block, kind = block+1, markdownBlockKind(node)
 		}
 
-		convertMarkdownNodeToMycomarkup(node, source, &output, &warnings)
+		convertMarkdownNodeToMycomarkup(node, source, &output, &messages)
+		for _, message := range messages[len(warnings):] {
+			warnings = append(warnings, warning{block, kind, message})
+		}
 
 		return ast.WalkContinue, nil
 	})
@@ -417,6 +593,32 @@ func MarkdownToMycomarkup(content string) (string, []string) {
 	return output.String(), warnings
 }
 
+// markdownBlockKind names the kind of the block like mycoBlockKind does.
+func markdownBlockKind(node ast.Node) string {
+	switch node.(type) {
+	case *ast.Heading:
+		return "heading"
+	case *ast.Paragraph:
+		return "paragraph"
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		return "code block"
+	case *ast.List:
+		return "list"
+	case *ast.ThematicBreak:
+		return "thematic break"
+	case *ast.Blockquote:
+		return "quote"
+	case *gast.Table:
+		return "table"
+	case *ast.HTMLBlock:
+		return "html"
+	case *mdrenderer.Transclusion:
+		return "transclusion"
+	default:
+		return node.Kind().String()
+	}
+}
+
 func convertMarkdownNodeToMycomarkup(node ast.Node, source []byte, output *strings.Builder, warnings *[]string) {
 	switch n := node.(type) {
 	case *ast.Heading:
@@ -513,6 +715,23 @@ func convertMarkdownNodeToMycomarkup(node ast.Node, source []byte, output *strin
 	case *gast.Table:
 		convertGFMTableToMycomarkup(n, source, output, warnings)
 
+	case *ast.HTMLBlock:
+		// Mycomarkup has no HTML, so the markup is kept in a code block for the author to redo by hand.
+		*warnings = append(*warnings, "HTML block was kept as a code block because Mycomarkup has no HTML")
+		output.WriteString("```html\n")
+		lines := n.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			line := lines.At(i)
+			output.Write(line.Value(source))
+		}
+		if n.HasClosure() {
+			output.Write(n.ClosureLine.Value(source))
+		}
+		output.WriteString("```\n")
+
+	case *mdrenderer.Transclusion:
+		output.WriteString("<= " + n.Line + "\n")
+
 	case *ast.Image:
 		// Will be handled as inline node
 		return
@@ -613,26 +832,39 @@ func convertMarkdownInlineNode(node ast.Node, source []byte, output *strings.Bui
 
 	case *ast.Image:
 		// Convert markdown ![alt](src) to img{} block or inline HTML
+		// The description goes in braces, because | starts the size of the image in Mycomarkup.
 		output.WriteString("img { ")
 		output.Write(n.Destination)
-		if n.Title != nil {
-			output.WriteString(" | ")
-			output.Write(n.Title)
-		} else if n.FirstChild() != nil {
-			// Use alt text as description
-			var altText strings.Builder
-			for child := n.FirstChild(); child != nil; child = child.NextSibling() {
-				if text, ok := child.(*ast.Text); ok {
-					altText.Write(text.Segment.Value(source))
-				}
-			}
-			if alt := altText.String(); alt != "" {
-				output.WriteString(" | ")
-				output.WriteString(alt)
+		var altText strings.Builder
+		for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+			if text, ok := child.(*ast.Text); ok {
+				altText.Write(text.Segment.Value(source))
 			}
 		}
+		description := altText.String()
+		if description == "" && n.Title != nil {
+			description = string(n.Title)
+		}
+		if description != "" {
+			output.WriteString(" { ")
+			output.WriteString(description)
+			output.WriteString(" }")
+		}
 		output.WriteString(" }")
 
+	case *mdrenderer.WikiLink:
+		// [[target|label]] -> [[target | label]]
+		// The destination is kept as written, so that relative links are resolved by Mycomarkup the same way.
+		output.WriteString("[[")
+		output.WriteString(n.Destination)
+		var linkText strings.Builder
+		convertMarkdownInlineChildren(n, source, &linkText, warnings)
+		if text := linkText.String(); text != n.Destination {
+			output.WriteString(" | ")
+			output.WriteString(text)
+		}
+		output.WriteString("]]")
+
 	case *gast.Strikethrough:
 		// GFM strikethrough ~~text~~ -> Mycomarkup ~~text~~
 		output.WriteString("~~")
@@ -647,59 +879,42 @@ func convertMarkdownInlineNode(node ast.Node, source []byte, output *strings.Bui
 
 func convertGFMTableToMycomarkup(table *gast.Table, source []byte, output *strings.Builder, warnings *[]string) {
 	output.WriteString("table {\n")
-
 	for row := table.FirstChild(); row != nil; row = row.NextSibling() {
-		switch r := row.(type) {
-		case *gast.TableRow:
-			for cell := r.FirstChild(); cell != nil; cell = cell.NextSibling() {
-				if c, ok := cell.(*gast.TableCell); ok {
-					// Determine if header
-					var cellMarker string
-					if c.Alignment == gast.AlignNone {
-						cellMarker = ""
-					} else {
-						cellMarker = "th"
-					}
-
-					if cellMarker != "" {
-						output.WriteString(cellMarker)
-						output.WriteString(" { ")
-					}
-
-					// Convert cell contents
-					var cellContent strings.Builder
-					for child := c.FirstChild(); child != nil; child = child.NextSibling() {
-						convertMarkdownInlineNode(child, source, &cellContent, warnings)
-					}
-					output.WriteString(strings.TrimSpace(cellContent.String()))
-
-					if cellMarker != "" {
-						output.WriteString(" }")
-					}
-					output.WriteString("\n")
-				}
-			}
+		// Header cells start with !, the rest with |
+		marker := "|"
+		if row.Kind() == gast.KindTableHeader {
+			marker = "!"
+		}
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			var cellContent strings.Builder
+			convertMarkdownInlineChildren(cell, source, &cellContent, warnings)
+			output.WriteString(marker + " " + strings.TrimSpace(cellContent.String()) + " ")
 		}
+		output.WriteString("\n")
 	}
-
 	output.WriteString("}\n")
 }
 
 // ConvertFormat is the main entry point for format conversion
 func ConvertFormat(content string, from, to hyphae.TextFormat) (string, []string, error) {
+	result, warnings, err := convertFormat(content, from, to)
+	return result, warningMessages(warnings), err
+}
+
+func convertFormat(content string, from, to hyphae.TextFormat) (string, []warning, error) {
 	if from == to {
-		return content, []string{"No conversion needed - already in target format"}, nil
+		return content, []warning{{-1, "", "No conversion needed - already in target format"}}, nil
 	}
 
 	if from == hyphae.FormatMycomarkup && to == hyphae.FormatMarkdown {
-		result, warnings := MycomarkupToMarkdown(content)
+		result, warnings := mycomarkupToMarkdown(content)
 		return result, warnings, nil
 	}
 
 	if from == hyphae.FormatMarkdown && to == hyphae.FormatMycomarkup {
-		result, warnings := MarkdownToMycomarkup(content)
+		result, warnings := markdownToMycomarkup(content)
 		return result, warnings, nil
 	}
 
-	return content, []string{"Unknown conversion"}, nil
+	return content, []warning{{-1, "", "Unknown conversion"}}, nil
 }
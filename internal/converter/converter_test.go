@@ -333,6 +333,26 @@ func TestMarkdownToMycomarkup_Links(t *testing.T) {
 			input: "[Google](https://google.com)",
 			want:  "[[https://google.com | Google]]\n",
 		},
+		{
+			name:  "wikilink with mixed case",
+			input: "[[Team Page]]",
+			want:  "[[Team Page]]\n",
+		},
+		{
+			name:  "relative wikilink",
+			input: "[[./Sub]]",
+			want:  "[[./Sub]]\n",
+		},
+		{
+			name:  "relative wikilink with label",
+			input: "[[../Sibling | the sibling]]",
+			want:  "[[../Sibling | the sibling]]\n",
+		},
+		{
+			name:  "wikilink with anchor",
+			input: "[[Team Page#Members]]",
+			want:  "[[Team Page#Members]]\n",
+		},
 	}
 
 	for _, tt := range tests {
@@ -527,22 +547,55 @@ func TestMycomarkupToMarkdown_Images(t *testing.T) {
 }
 
 func TestMycomarkupToMarkdown_Tables(t *testing.T) {
-	// Tables should convert to HTML
-	input := `table {
-th { Header 1 }
-th { Header 2 }
-Cell 1
-Cell 2
-}`
-
-	got, _ := MycomarkupToMarkdown(input)
-
-	// Should contain HTML table elements
-	if !strings.Contains(got, "<table>") {
-		t.Errorf("Expected HTML table output, got: %q", got)
+	tests := []struct {
+		name        string
+		input       string
+		want        string
+		wantWarning string
+	}{
+		{
+			name:  "simple table becomes pipe table",
+			input: "table {\n! Monday ! Friday\n| sad **day** | happy day\n}",
+			want:  "| Monday | Friday |\n| --- | --- |\n| sad **day** | happy day |\n",
+		},
+		{
+			name:  "pipes in cells are escaped",
+			input: "table {\n! Command ! Meaning\n| `a \\| b` | pipe\n}",
+			want:  "| Command | Meaning |\n| --- | --- |\n| `a \\| b` | pipe |\n",
+		},
+		{
+			name:        "caption falls back to HTML",
+			input:       "table { Week\n! Monday ! Friday\n| sad | happy\n}",
+			want:        "<caption> Week</caption>",
+			wantWarning: "Table ‘Week’ was converted to HTML because pipe tables have no captions",
+		},
+		{
+			name:        "colspan falls back to HTML",
+			input:       "table {\n! Monday ! Friday\n|| both\n}",
+			want:        `<td colspan="2">`,
+			wantWarning: "Table was converted to HTML because cell 1 of row 2 spans 2 columns",
+		},
+		{
+			name:        "no header row falls back to HTML",
+			input:       "table {\n| a | b\n}",
+			want:        "<table>",
+			wantWarning: "Table was converted to HTML because its first row is not a header row",
+		},
 	}
-	if !strings.Contains(got, "</table>") {
-		t.Errorf("Expected closing table tag, got: %q", got)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, warnings := MycomarkupToMarkdown(tt.input)
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("MycomarkupToMarkdown() = %q, want to contain %q", got, tt.want)
+			}
+			if tt.wantWarning == "" && len(warnings) > 0 {
+				t.Errorf("MycomarkupToMarkdown() warnings = %q, want none", warnings)
+			}
+			if tt.wantWarning != "" && (len(warnings) != 1 || warnings[0] != tt.wantWarning) {
+				t.Errorf("MycomarkupToMarkdown() warnings = %q, want %q", warnings, tt.wantWarning)
+			}
+		})
 	}
 }
 
@@ -565,7 +618,7 @@ func TestMarkdownToMycomarkup_Images(t *testing.T) {
 		{
 			name:  "image with alt text",
 			input: "![Alt text](image.png)",
-			want:  "img { image.png | Alt text }\n",
+			want:  "img { image.png { Alt text } }\n",
 		},
 		{
 			name:  "image without alt",
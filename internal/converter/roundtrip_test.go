@@ -0,0 +1,337 @@
+package converter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bouncepaw/mycorrhiza/internal/diff"
+	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/mdrenderer"
+	"github.com/bouncepaw/mycorrhiza/mycoopts"
+
+	"git.sr.ht/~bouncepaw/mycomarkup/v5"
+	"git.sr.ht/~bouncepaw/mycomarkup/v5/blocks"
+	"git.sr.ht/~bouncepaw/mycomarkup/v5/genhtml"
+	"git.sr.ht/~bouncepaw/mycomarkup/v5/mycocontext"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// The round-trip harness converts the documents in testdata/roundtrip to the other format and back, and compares the result with the original. It reports which block kinds lose information, and fails if the converter did not warn about a loss. Run with -v to see the report.
+
+// normalBlock is a block of a normalized AST. Both formats are normalized to the same kinds, so that they can be compared.
+type normalBlock struct {
+	Kind string
+	Text string
+}
+
+func (b normalBlock) String() string {
+	return b.Kind + ": " + b.Text
+}
+
+var (
+	spaceRe = regexp.MustCompile(`\s+`)
+	tagRe   = regexp.MustCompile(`<.*?>`)
+	idRe    = regexp.MustCompile(` id="[^"]*"`)
+)
+
+// normalizeText collapses whitespace and strips HTML tags, which is what both formats fall back to for unsupported markup.
+func normalizeText(s string) string {
+	return strings.TrimSpace(spaceRe.ReplaceAllString(tagRe.ReplaceAllString(s, " "), " "))
+}
+
+// normalizeHTML makes rendered HTML comparable. Heading ids are generated differently, and whitespace does not matter.
+func normalizeHTML(s string) string {
+	s = idRe.ReplaceAllString(s, "")
+	s = strings.ReplaceAll(s, "<br/>", "<br>")
+	s = regexp.MustCompile(`>\s+`).ReplaceAllString(s, ">")
+	s = regexp.MustCompile(`\s+<`).ReplaceAllString(s, "<")
+	return strings.TrimSpace(spaceRe.ReplaceAllString(s, " "))
+}
+
+func normalizeMyco(content string) (normal []normalBlock, html string) {
+	ctx, _ := mycocontext.ContextFromStringInput(content, mycoopts.MarkupOptions(""))
+	tree := mycomarkup.BlockTree(ctx)
+	for _, block := range tree {
+		var blockText string
+		switch block := block.(type) {
+		case blocks.CodeBlock:
+			blockText = strings.TrimSpace(block.Contents())
+		case blocks.Transclusion:
+			blockText = block.Target
+		default:
+			blockText = genhtml.BlockToTag(ctx, block).String()
+		}
+		normal = append(normal, normalBlock{mycoBlockKind(block), normalizeText(blockText)})
+	}
+	return normal, normalizeHTML(mycomarkup.BlocksToHTML(ctx, tree))
+}
+
+func normalizeMarkdown(content string) (normal []normalBlock, html string) {
+	source := []byte(content)
+	doc := mdrenderer.Markdown.Parser().Parse(text.NewReader(source))
+	for node := doc.FirstChild(); node != nil; node = node.NextSibling() {
+		var blockText string
+		switch node := node.(type) {
+		case *mdrenderer.Transclusion:
+			blockText = node.Target()
+		case *ast.HTMLBlock:
+			var buf strings.Builder
+			for i := 0; i < node.Lines().Len(); i++ {
+				line := node.Lines().At(i)
+				buf.Write(line.Value(source))
+			}
+			blockText = buf.String()
+		default:
+			blockText = mdrenderer.PlainText(node, source)
+		}
+		normal = append(normal, normalBlock{markdownBlockKind(node), normalizeText(blockText)})
+	}
+	rendered, _ := mdrenderer.Render(source)
+	return normal, normalizeHTML(rendered)
+}
+
+func normalize(content string, format hyphae.TextFormat) ([]normalBlock, string) {
+	if format == hyphae.FormatMarkdown {
+		return normalizeMarkdown(content)
+	}
+	return normalizeMyco(content)
+}
+
+// kindFidelity is how well blocks of one kind survive the round trip. A loss is predicted if there is a warning about the lost block.
+type kindFidelity struct {
+	total, lost, predicted int
+	examples               []string
+}
+
+// fidelityReport collects round-trip results over a corpus.
+type fidelityReport struct {
+	kinds        map[string]*kindFidelity
+	docs         int
+	htmlDiffers  []string
+	unpredicted  []string
+	conversionOK int
+}
+
+func newFidelityReport() *fidelityReport {
+	return &fidelityReport{kinds: make(map[string]*kindFidelity)}
+}
+
+// predicts tells whether one of the warnings is about the block of the given kind at the given position of the original document.
+//
+// The warnings of the conversion there are about the original blocks. The warnings of the conversion back are about the blocks of the converted document. The converter writes a block for every block, so those are at the same positions, but their kinds might differ: a table might become an HTML block, for example. Such warnings are only matched by position, and only if the converted document has as many blocks as the original one.
+func predicts(warningsThere, warningsBack []warning, sameBlocks bool, kind string, position int) bool {
+	for _, w := range warningsThere {
+		if w.block == position && w.kind == kind {
+			return true
+		}
+	}
+	for _, w := range warningsBack {
+		if sameBlocks && w.block == position {
+			return true
+		}
+	}
+	return false
+}
+
+// roundTrip converts the document from its format to the other one and back, and records what got lost. Losses without warnings about them are reported as test errors.
+func (r *fidelityReport) roundTrip(t *testing.T, name, content string, from hyphae.TextFormat) {
+	t.Helper()
+	to := hyphae.FormatMarkdown
+	if from == hyphae.FormatMarkdown {
+		to = hyphae.FormatMycomarkup
+	}
+
+	there, warningsThere, err := convertFormat(content, from, to)
+	if err != nil {
+		t.Errorf("%s: failed to convert to %s: %v", name, hyphae.FormatName(to), err)
+		return
+	}
+	back, warningsBack, err := convertFormat(there, to, from)
+	if err != nil {
+		t.Errorf("%s: failed to convert back to %s: %v", name, hyphae.FormatName(from), err)
+		return
+	}
+	r.docs++
+
+	original, originalHTML := normalize(content, from)
+	converted, _ := normalize(there, to)
+	roundTripped, roundTrippedHTML := normalize(back, from)
+
+	var originalTokens, roundTrippedTokens []string
+	for _, block := range original {
+		originalTokens = append(originalTokens, block.String())
+		kind := r.kind(block.Kind)
+		kind.total++
+	}
+	for _, block := range roundTripped {
+		roundTrippedTokens = append(roundTrippedTokens, block.String())
+	}
+
+	// position is the index of the current block of the original document.
+	position := 0
+	for _, edit := range diff.Diff(originalTokens, roundTrippedTokens) {
+		if edit.Kind == diff.Insert {
+			continue
+		}
+		if edit.Kind == diff.Equal {
+			position++
+			continue
+		}
+		kindName := original[position].Kind
+		kind := r.kind(kindName)
+		kind.lost++
+		if predicts(warningsThere, warningsBack, len(converted) == len(original), kindName, position) {
+			kind.predicted++
+		} else {
+			r.unpredicted = append(r.unpredicted, fmt.Sprintf("%s: block %d, %s", name, position+1, shorten(edit.Text, 80)))
+			t.Errorf("%s: block %d was lost without a warning: %s", name, position+1, shorten(edit.Text, 80))
+		}
+		if len(kind.examples) < 3 {
+			kind.examples = append(kind.examples, name+": "+shorten(edit.Text, 80))
+		}
+		position++
+	}
+	if originalHTML != roundTrippedHTML {
+		r.htmlDiffers = append(r.htmlDiffers, name)
+	} else {
+		r.conversionOK++
+	}
+}
+
+func (r *fidelityReport) kind(name string) *kindFidelity {
+	if _, ok := r.kinds[name]; !ok {
+		r.kinds[name] = &kindFidelity{}
+	}
+	return r.kinds[name]
+}
+
+func (r *fidelityReport) String() string {
+	var (
+		buf   strings.Builder
+		names []string
+	)
+	for name := range r.kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(&buf, "%d documents, %d with identical HTML after the round trip\n", r.docs, r.conversionOK)
+	fmt.Fprintf(&buf, "%-16s %6s %6s %10s\n", "block kind", "total", "lost", "predicted")
+	for _, name := range names {
+		kind := r.kinds[name]
+		fmt.Fprintf(&buf, "%-16s %6d %6d %10d\n", name, kind.total, kind.lost, kind.predicted)
+	}
+	for _, name := range names {
+		for _, example := range r.kinds[name].examples {
+			fmt.Fprintf(&buf, "lost %s — %s\n", name, example)
+		}
+	}
+	if len(r.htmlDiffers) > 0 {
+		fmt.Fprintf(&buf, "HTML differs: %s\n", strings.Join(r.htmlDiffers, ", "))
+	}
+	if len(r.unpredicted) > 0 {
+		fmt.Fprintf(&buf, "Lost without warnings:\n%s\n", strings.Join(r.unpredicted, "\n"))
+	}
+	return buf.String()
+}
+
+func shorten(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n]) + "…"
+	}
+	return s
+}
+
+// roundTripCorpus returns the documents to run the harness over. They are fixed, so that the results only change with the converter.
+func roundTripCorpus(t *testing.T) []string {
+	corpus, err := filepath.Glob("testdata/roundtrip/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corpus) == 0 {
+		t.Fatal("no documents in testdata/roundtrip")
+	}
+	return corpus
+}
+
+func TestRoundTripFidelity(t *testing.T) {
+	var (
+		mycoReport     = newFidelityReport()
+		markdownReport = newFidelityReport()
+	)
+	for _, path := range roundTripCorpus(t) {
+		format := hyphae.DetectTextFormat(path)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := filepath.Base(path)
+		if format == hyphae.FormatMarkdown {
+			markdownReport.roundTrip(t, name, string(content), format)
+		} else {
+			mycoReport.roundTrip(t, name, string(content), format)
+		}
+	}
+	t.Logf("Mycomarkup → Markdown → Mycomarkup\n%s", mycoReport)
+	t.Logf("Markdown → Mycomarkup → Markdown\n%s", markdownReport)
+}
+
+// These block kinds have direct equivalents in both formats, so the round trip must keep them intact.
+func TestRoundTripKeepsSimpleBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  hyphae.TextFormat
+	}{
+		{"myco headings", "= One\n\n== Two\n\n=== Three", hyphae.FormatMycomarkup},
+		{"myco paragraphs", "First **bold** //italic//.\n\nSecond.", hyphae.FormatMycomarkup},
+		{"myco code block", "```\ncode\n  indented\n```", hyphae.FormatMycomarkup},
+		{"myco thematic break", "a\n\n----\n\nb", hyphae.FormatMycomarkup},
+		{"md headings", "# One\n\n## Two", hyphae.FormatMarkdown},
+		{"md paragraphs", "First **bold** *italic*.\n\nSecond.", hyphae.FormatMarkdown},
+		{"md code block", "```go\nfunc main() {}\n```", hyphae.FormatMarkdown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := newFidelityReport()
+			report.roundTrip(t, tt.name, tt.content, tt.format)
+			for kind, fidelity := range report.kinds {
+				if fidelity.lost > 0 {
+					t.Errorf("round trip lost %d %s block(s)\n%s", fidelity.lost, kind, report)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundTripPredicts(t *testing.T) {
+	warnings := []warning{{2, "table", "Table was converted to HTML because of reasons"}}
+	tests := []struct {
+		name          string
+		there, back   []warning
+		sameBlocks    bool
+		kind          string
+		position      int
+		wantPredicted bool
+	}{
+		{"same kind and position", warnings, nil, true, "table", 2, true},
+		{"other position", warnings, nil, true, "table", 3, false},
+		{"other kind", warnings, nil, true, "paragraph", 2, false},
+		{"no warnings", nil, nil, true, "table", 2, false},
+		{"converted block at the position", nil, []warning{{2, "html", "HTML block was kept"}}, true, "table", 2, true},
+		{"converted blocks moved", nil, []warning{{2, "html", "HTML block was kept"}}, false, "table", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := predicts(tt.there, tt.back, tt.sameBlocks, tt.kind, tt.position); got != tt.wantPredicted {
+				t.Errorf("predicts() = %v, want %v", got, tt.wantPredicted)
+			}
+		})
+	}
+}
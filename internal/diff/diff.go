@@ -0,0 +1,238 @@
+// Package diff finds differences between texts, line by line or word by word.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OpKind is the kind of an edit.
+type OpKind int
+
+const (
+	// Equal means the token is in both texts.
+	Equal OpKind = iota
+	// Insert means the token is only in the new text.
+	Insert
+	// Delete means the token is only in the old text.
+	Delete
+)
+
+func (k OpKind) String() string {
+	switch k {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// Edit is one token of a difference.
+type Edit struct {
+	Kind OpKind
+	Text string
+}
+
+// maxEditDistance limits the work Diff does. If the texts differ by more tokens than this, they are not compared closely.
+const maxEditDistance = 1000
+
+// Diff returns the shortest edit script that turns a into b. It is the Myers algorithm. If the texts are too different, see maxEditDistance, the script is not the shortest one: everything between the common prefix and suffix is deleted and inserted anew.
+func Diff(a, b []string) []Edit {
+	// Common prefix and suffix are cheap to find and usually large.
+	var prefix, suffix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var edits []Edit
+	for _, tok := range a[:prefix] {
+		edits = append(edits, Edit{Equal, tok})
+	}
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if middle, ok := myers(middleA, middleB); ok {
+		edits = append(edits, middle...)
+	} else {
+		for _, tok := range middleA {
+			edits = append(edits, Edit{Delete, tok})
+		}
+		for _, tok := range middleB {
+			edits = append(edits, Edit{Insert, tok})
+		}
+	}
+	for _, tok := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Equal, tok})
+	}
+	return edits
+}
+
+// myers returns the shortest edit script. It is not found if it is longer than maxEditDistance.
+func myers(a, b []string) ([]Edit, bool) {
+	var (
+		n, m = len(a), len(b)
+		max  = n + m
+		v    = make([]int, 2*max+2)
+		// trace[d] is the part of v for the diagonals -d to d before the step d. Only these are used on the way back.
+		trace [][]int
+	)
+	if max == 0 {
+		return nil, true
+	}
+
+search:
+	for d := 0; d <= max; d++ {
+		if d > maxEditDistance {
+			return nil, false
+		}
+		trace = append(trace, append([]int(nil), v[max-d:max+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace backwards to recover the path.
+	var (
+		edits []Edit
+		x, y  = n, m
+	)
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[d+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, Edit{Equal, a[x-1]})
+			x, y = x-1, y-1
+		}
+		if x == prevX {
+			edits = append(edits, Edit{Insert, b[y-1]})
+		} else {
+			edits = append(edits, Edit{Delete, a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	// The first snake goes from the very beginning.
+	for x > 0 {
+		edits = append(edits, Edit{Equal, a[x-1]})
+		x--
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits, true
+}
+
+// Lines splits the text into lines without line terminators.
+func Lines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Unified returns the difference between the texts in the unified diff format with the given number of context lines. If the texts are equal, an empty string is returned.
+func Unified(fromName, toName, from, to string, context int) string {
+	edits := Diff(Lines(from), Lines(to))
+
+	var (
+		buf      strings.Builder
+		oldLine  = 1
+		newLine  = 1
+		firstRun = true
+	)
+	for i := 0; i < len(edits); {
+		if edits[i].Kind == Equal {
+			i, oldLine, newLine = i+1, oldLine+1, newLine+1
+			continue
+		}
+
+		// A hunk starts with context lines before the change...
+		start := i
+		for start > 0 && i-start < context && edits[start-1].Kind == Equal {
+			start--
+		}
+		// ...and goes on while the changes are closer than two contexts to each other.
+		end := i
+		for end < len(edits) {
+			if edits[end].Kind != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].Kind == Equal {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end += min(context, run-end)
+				break
+			}
+			end = run
+		}
+
+		var (
+			hunk             strings.Builder
+			oldStart         = oldLine - (i - start)
+			newStart         = newLine - (i - start)
+			oldCount, newCnt int
+		)
+		for _, e := range edits[start:end] {
+			switch e.Kind {
+			case Equal:
+				hunk.WriteString(" " + e.Text + "\n")
+				oldCount, newCnt = oldCount+1, newCnt+1
+			case Delete:
+				hunk.WriteString("-" + e.Text + "\n")
+				oldCount++
+			case Insert:
+				hunk.WriteString("+" + e.Text + "\n")
+				newCnt++
+			}
+		}
+
+		if firstRun {
+			fmt.Fprintf(&buf, "--- %s\n+++ %s\n", fromName, toName)
+			firstRun = false
+		}
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCnt))
+		buf.WriteString(hunk.String())
+
+		oldLine, newLine = oldStart+oldCount, newStart+newCnt
+		i = end
+	}
+	return buf.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		// This is how GNU diff marks an empty range: it points to the line before.
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
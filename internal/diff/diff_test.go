@@ -0,0 +1,200 @@
+package diff
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// apply turns the edits back into the texts they were made from.
+func apply(edits []Edit) (a, b []string) {
+	for _, edit := range edits {
+		if edit.Kind != Insert {
+			a = append(a, edit.Text)
+		}
+		if edit.Kind != Delete {
+			b = append(b, edit.Text)
+		}
+	}
+	return a, b
+}
+
+func changes(edits []Edit) (n int) {
+	for _, edit := range edits {
+		if edit.Kind != Equal {
+			n++
+		}
+	}
+	return n
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []string
+		b    []string
+		want []Edit
+	}{
+		{
+			name: "both empty",
+		},
+		{
+			name: "equal",
+			a:    []string{"a", "b"},
+			b:    []string{"a", "b"},
+			want: []Edit{{Equal, "a"}, {Equal, "b"}},
+		},
+		{
+			name: "from empty",
+			b:    []string{"a", "b"},
+			want: []Edit{{Insert, "a"}, {Insert, "b"}},
+		},
+		{
+			name: "to empty",
+			a:    []string{"a", "b"},
+			want: []Edit{{Delete, "a"}, {Delete, "b"}},
+		},
+		{
+			name: "changed line in the middle",
+			a:    []string{"a", "b", "c"},
+			b:    []string{"a", "B", "c"},
+			want: []Edit{{Equal, "a"}, {Delete, "b"}, {Insert, "B"}, {Equal, "c"}},
+		},
+		{
+			name: "insertion and deletion",
+			a:    []string{"a", "b", "c", "d"},
+			b:    []string{"x", "a", "c", "d", "y"},
+			want: []Edit{{Insert, "x"}, {Equal, "a"}, {Delete, "b"}, {Equal, "c"}, {Equal, "d"}, {Insert, "y"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.a, tt.b); !slices.Equal(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiff_Shortest(t *testing.T) {
+	tests := []struct {
+		name        string
+		a           string
+		b           string
+		wantChanges int
+	}{
+		{"abcabba to cbabac", "abcabba", "cbabac", 5},
+		{"reordered", "abcdef", "fedcba", 10},
+		{"one letter apart", "kitten", "sitting", 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := letters(tt.a), letters(tt.b)
+			edits := Diff(a, b)
+			if gotA, gotB := apply(edits); !slices.Equal(gotA, a) || !slices.Equal(gotB, b) {
+				t.Fatalf("Diff() = %v does not turn %q into %q", edits, tt.a, tt.b)
+			}
+			if got := changes(edits); got != tt.wantChanges {
+				t.Errorf("Diff() has %d changes, want %d", got, tt.wantChanges)
+			}
+		})
+	}
+}
+
+func TestDiff_TooDifferent(t *testing.T) {
+	var a, b []string
+	for i := 0; i < maxEditDistance; i++ {
+		a = append(a, "a"+strconv.Itoa(i))
+		b = append(b, "b"+strconv.Itoa(i))
+	}
+	a = append([]string{"same"}, append(a, "end")...)
+	b = append([]string{"same"}, append(b, "end")...)
+
+	edits := Diff(a, b)
+	if gotA, gotB := apply(edits); !slices.Equal(gotA, a) || !slices.Equal(gotB, b) {
+		t.Fatalf("Diff() does not turn a into b")
+	}
+	if got, want := changes(edits), 2*maxEditDistance; got != want {
+		t.Errorf("Diff() has %d changes, want %d", got, want)
+	}
+	if edits[0] != (Edit{Equal, "same"}) || edits[len(edits)-1] != (Edit{Equal, "end"}) {
+		t.Errorf("Diff() lost the common prefix or suffix: %v, %v", edits[0], edits[len(edits)-1])
+	}
+}
+
+func letters(s string) (result []string) {
+	for _, r := range s {
+		result = append(result, string(r))
+	}
+	return result
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		context int
+		want    string
+	}{
+		{
+			name:    "equal",
+			from:    "a\nb\n",
+			to:      "a\nb\n",
+			context: 3,
+			want:    "",
+		},
+		{
+			name:    "one changed line",
+			from:    "a\nb\nc\n",
+			to:      "a\nB\nc\n",
+			context: 3,
+			want:    "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:    "limited context",
+			from:    "1\n2\n3\n4\n5\n6\n7\n",
+			to:      "1\n2\n3\nfour\n5\n6\n7\n",
+			context: 1,
+			want:    "--- old\n+++ new\n@@ -3,3 +3,3 @@\n 3\n-4\n+four\n 5\n",
+		},
+		{
+			name:    "two hunks",
+			from:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			to:      "one\n2\n3\n4\n5\n6\n7\n8\nnine\n",
+			context: 1,
+			want:    "--- old\n+++ new\n@@ -1,2 +1,2 @@\n-1\n+one\n 2\n@@ -8,2 +8,2 @@\n 8\n-9\n+nine\n",
+		},
+		{
+			name:    "close changes share a hunk",
+			from:    "1\n2\n3\n4\n5\n",
+			to:      "one\n2\n3\nfour\n5\n",
+			context: 1,
+			want:    "--- old\n+++ new\n@@ -1,5 +1,5 @@\n-1\n+one\n 2\n 3\n-4\n+four\n 5\n",
+		},
+		{
+			name:    "from empty",
+			from:    "",
+			to:      "a\nb\n",
+			context: 3,
+			want:    "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:    "to empty",
+			from:    "a\n",
+			to:      "",
+			context: 3,
+			want:    "--- old\n+++ new\n@@ -1 +0,0 @@\n-a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Unified("old", "new", tt.from, tt.to, tt.context); got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+)
+
+// htmlTagPattern matches tags and character references, which are not split into words.
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>|&#?\w+;`)
+
+// HTML compares two HTML documents word by word and returns the new one with the deleted text put back in <del> elements and the inserted text wrapped in <ins> elements. Changed tags are taken from the new document only, so the result stays well-formed.
+func HTML(from, to string) string {
+	var buf strings.Builder
+	for _, e := range Diff(htmlTokens(from), htmlTokens(to)) {
+		isTag := strings.HasPrefix(e.Text, "<")
+		switch {
+		case e.Kind == Equal:
+			buf.WriteString(e.Text)
+		case isTag && e.Kind == Insert:
+			buf.WriteString(e.Text)
+		case isTag:
+			// Deleted tags are dropped, their text is kept.
+		case strings.TrimSpace(e.Text) == "":
+			if e.Kind == Insert {
+				buf.WriteString(e.Text)
+			}
+		case e.Kind == Insert:
+			buf.WriteString(`<ins class="diff__ins">` + e.Text + `</ins>`)
+		default:
+			buf.WriteString(`<del class="diff__del">` + e.Text + `</del>`)
+		}
+	}
+	return buf.String()
+}
+
+// htmlTokens splits the HTML into tags, character references and the words of the text between them.
+func htmlTokens(html string) []string {
+	var (
+		tokens []string
+		prev   int
+	)
+	for _, loc := range htmlTagPattern.FindAllStringIndex(html, -1) {
+		tokens = append(tokens, Words(html[prev:loc[0]])...)
+		tokens = append(tokens, html[loc[0]:loc[1]])
+		prev = loc[1]
+	}
+	return append(tokens, Words(html[prev:])...)
+}
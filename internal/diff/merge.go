@@ -0,0 +1,68 @@
+package diff
+
+import "slices"
+
+// Merge combines the changes two texts made to their common base, line by line. Where both texts changed the same lines differently, both variants are kept between conflict markers labeled with oursLabel and theirsLabel, like git does. The number of such conflicts is returned too.
+func Merge(base, ours, theirs []string, oursLabel, theirsLabel string) (merged []string, conflicts int) {
+	var (
+		inOurs   = matches(base, ours)
+		inTheirs = matches(base, theirs)
+		i, j, k  int // Positions in base, ours and theirs.
+	)
+	for {
+		// Find the next base line both texts kept. Everything before it is a chunk that might have changed.
+		sync := i
+		for sync < len(base) && (inOurs[sync] < 0 || inTheirs[sync] < 0) {
+			sync++
+		}
+		ourEnd, theirEnd := len(ours), len(theirs)
+		if sync < len(base) {
+			ourEnd, theirEnd = inOurs[sync], inTheirs[sync]
+		}
+
+		var (
+			baseChunk   = base[i:sync]
+			ourChunk    = ours[j:ourEnd]
+			theirChunk  = theirs[k:theirEnd]
+			oursChanged = !slices.Equal(baseChunk, ourChunk)
+		)
+		switch {
+		case !oursChanged:
+			merged = append(merged, theirChunk...)
+		case slices.Equal(baseChunk, theirChunk), slices.Equal(ourChunk, theirChunk):
+			merged = append(merged, ourChunk...)
+		default:
+			conflicts++
+			merged = append(merged, "<<<<<<< "+oursLabel)
+			merged = append(merged, ourChunk...)
+			merged = append(merged, "=======")
+			merged = append(merged, theirChunk...)
+			merged = append(merged, ">>>>>>> "+theirsLabel)
+		}
+
+		if sync == len(base) {
+			return merged, conflicts
+		}
+		merged = append(merged, base[sync])
+		i, j, k = sync+1, ourEnd+1, theirEnd+1
+	}
+}
+
+// matches returns, for every line of a, the index of the same line in b if the line is kept by the diff, or -1.
+func matches(a, b []string) []int {
+	result := make([]int, len(a))
+	var i, j int
+	for _, edit := range Diff(a, b) {
+		switch edit.Kind {
+		case Equal:
+			result[i] = j
+			i, j = i+1, j+1
+		case Delete:
+			result[i] = -1
+			i++
+		case Insert:
+			j++
+		}
+	}
+	return result
+}
@@ -0,0 +1,117 @@
+package diff
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name          string
+		base          []string
+		ours          []string
+		theirs        []string
+		want          []string
+		wantConflicts int
+	}{
+		{
+			name:   "clean merge of separate changes",
+			base:   []string{"a", "b", "c", "d", "e"},
+			ours:   []string{"A", "b", "c", "d", "e"},
+			theirs: []string{"a", "b", "c", "d", "E"},
+			want:   []string{"A", "b", "c", "d", "E"},
+		},
+		{
+			name:   "only theirs changed",
+			base:   []string{"a", "b", "c"},
+			ours:   []string{"a", "b", "c"},
+			theirs: []string{"a", "B", "c", "d"},
+			want:   []string{"a", "B", "c", "d"},
+		},
+		{
+			name:   "only ours changed",
+			base:   []string{"a", "b", "c"},
+			ours:   []string{"a", "c"},
+			theirs: []string{"a", "b", "c"},
+			want:   []string{"a", "c"},
+		},
+		{
+			name:   "both sides made the same change",
+			base:   []string{"a", "b", "c"},
+			ours:   []string{"a", "B", "c"},
+			theirs: []string{"a", "B", "c"},
+			want:   []string{"a", "B", "c"},
+		},
+		{
+			name:   "conflict on the same line",
+			base:   []string{"a", "b", "c"},
+			ours:   []string{"a", "ours", "c"},
+			theirs: []string{"a", "theirs", "c"},
+			want: []string{
+				"a",
+				"<<<<<<< mine", "ours", "=======", "theirs", ">>>>>>> yours",
+				"c",
+			},
+			wantConflicts: 1,
+		},
+		{
+			name:   "conflict on adjacent lines",
+			base:   []string{"a", "b", "c", "d"},
+			ours:   []string{"a", "B", "c", "d"},
+			theirs: []string{"a", "b", "C", "d"},
+			want: []string{
+				"a",
+				"<<<<<<< mine", "B", "c", "=======", "b", "C", ">>>>>>> yours",
+				"d",
+			},
+			wantConflicts: 1,
+		},
+		{
+			name:   "two conflicts",
+			base:   []string{"a", "b", "c", "d", "e"},
+			ours:   []string{"A1", "b", "c", "d", "E1"},
+			theirs: []string{"A2", "b", "c", "d", "E2"},
+			want: []string{
+				"<<<<<<< mine", "A1", "=======", "A2", ">>>>>>> yours",
+				"b", "c", "d",
+				"<<<<<<< mine", "E1", "=======", "E2", ">>>>>>> yours",
+			},
+			wantConflicts: 2,
+		},
+		{
+			name:   "empty base with different texts",
+			base:   nil,
+			ours:   []string{"ours"},
+			theirs: []string{"theirs"},
+			want:   []string{"<<<<<<< mine", "ours", "=======", "theirs", ">>>>>>> yours"},
+
+			wantConflicts: 1,
+		},
+		{
+			name:   "empty base with the same text",
+			base:   nil,
+			ours:   []string{"same", "text"},
+			theirs: []string{"same", "text"},
+			want:   []string{"same", "text"},
+		},
+		{
+			name:   "empty base and empty ours",
+			base:   nil,
+			ours:   nil,
+			theirs: []string{"theirs"},
+			want:   []string{"theirs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := Merge(tt.base, tt.ours, tt.theirs, "mine", "yours")
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Merge() = %q, want %q", got, tt.want)
+			}
+			if conflicts != tt.wantConflicts {
+				t.Errorf("Merge() conflicts = %d, want %d", conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
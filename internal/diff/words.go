@@ -0,0 +1,193 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Words splits the text into words, runs of spaces and single punctuation marks. Joined together, they make up the text again.
+func Words(text string) []string {
+	var (
+		words []string
+		start int
+	)
+	for i, r := range text {
+		if i > start && (wordClass(r) != wordClass(lastRune(text[start:i])) || wordClass(r) == punctClass) {
+			words = append(words, text[start:i])
+			start = i
+		}
+	}
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+	return words
+}
+
+const (
+	letterClass = iota
+	spaceClass
+	punctClass
+)
+
+func wordClass(r rune) int {
+	switch {
+	case unicode.IsLetter(r), unicode.IsDigit(r), r == '_':
+		return letterClass
+	case unicode.IsSpace(r):
+		return spaceClass
+	default:
+		return punctClass
+	}
+}
+
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
+
+// Line is a line of a line-by-line difference.
+type Line struct {
+	Kind OpKind
+	// OldNumber and NewNumber count from 1. They are 0 if the line is not in that text.
+	OldNumber, NewNumber int
+	// Words are the words of the line. If the line replaced a similar line, the words that differ are marked as deleted or inserted, otherwise all words are equal.
+	Words []Edit
+}
+
+// Text returns the line without markup.
+func (l Line) Text() string {
+	var buf strings.Builder
+	for _, word := range l.Words {
+		buf.WriteString(word.Text)
+	}
+	return buf.String()
+}
+
+// LineDiff compares the texts line by line. In every changed place, the deleted lines go before the inserted ones. They are compared word by word with the lines they replaced.
+func LineDiff(from, to string) []Line {
+	var (
+		edits          = Diff(Lines(from), Lines(to))
+		lines          []Line
+		oldNum, newNum = 1, 1
+	)
+	for i := 0; i < len(edits); {
+		if edits[i].Kind == Equal {
+			lines = append(lines, Line{Equal, oldNum, newNum, []Edit{{Equal, edits[i].Text}}})
+			i, oldNum, newNum = i+1, oldNum+1, newNum+1
+			continue
+		}
+
+		var deleted, inserted []string
+		for ; i < len(edits) && edits[i].Kind != Equal; i++ {
+			if edits[i].Kind == Delete {
+				deleted = append(deleted, edits[i].Text)
+			} else {
+				inserted = append(inserted, edits[i].Text)
+			}
+		}
+		oldWords := make([][]Edit, len(deleted))
+		newWords := make([][]Edit, len(inserted))
+		for j := range deleted {
+			oldWords[j] = []Edit{{Equal, deleted[j]}}
+		}
+		for j := range inserted {
+			newWords[j] = []Edit{{Equal, inserted[j]}}
+		}
+		for j := 0; j < len(deleted) && j < len(inserted); j++ {
+			if wordEdits, similar := compareWords(deleted[j], inserted[j]); similar {
+				oldWords[j], newWords[j] = nil, nil
+				for _, e := range wordEdits {
+					if e.Kind != Insert {
+						oldWords[j] = append(oldWords[j], e)
+					}
+					if e.Kind != Delete {
+						newWords[j] = append(newWords[j], e)
+					}
+				}
+			}
+		}
+		for _, words := range oldWords {
+			lines = append(lines, Line{Delete, oldNum, 0, words})
+			oldNum++
+		}
+		for _, words := range newWords {
+			lines = append(lines, Line{Insert, 0, newNum, words})
+			newNum++
+		}
+	}
+	return lines
+}
+
+// compareWords diffs the lines word by word. They are similar if at least a third of their text is the same, otherwise highlighting the words is not worth it.
+func compareWords(a, b string) (edits []Edit, similar bool) {
+	edits = Diff(Words(a), Words(b))
+	var same int
+	for _, e := range edits {
+		if e.Kind == Equal && strings.TrimSpace(e.Text) != "" {
+			same += len(e.Text)
+		}
+	}
+	return edits, 3*same >= max(len(a), len(b))
+}
+
+// Row is a row of a side-by-side difference. Old or New is nil if there is no line on that side.
+type Row struct {
+	Old, New *Line
+}
+
+// SideBySide puts the lines from LineDiff side by side. Deleted lines are put next to the lines inserted in their place.
+func SideBySide(lines []Line) []Row {
+	var rows []Row
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == Equal {
+			rows = append(rows, Row{&lines[i], &lines[i]})
+			i++
+			continue
+		}
+		var deleted, inserted []*Line
+		for ; i < len(lines) && lines[i].Kind == Delete; i++ {
+			deleted = append(deleted, &lines[i])
+		}
+		for ; i < len(lines) && lines[i].Kind == Insert; i++ {
+			inserted = append(inserted, &lines[i])
+		}
+		for j := 0; j < len(deleted) || j < len(inserted); j++ {
+			var row Row
+			if j < len(deleted) {
+				row.Old = deleted[j]
+			}
+			if j < len(inserted) {
+				row.New = inserted[j]
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// Hunks leaves only the changed lines with the given number of equal lines around them. Changes that are close to each other stay in one hunk.
+func Hunks(lines []Line, context int) [][]Line {
+	var (
+		hunks [][]Line
+		start = -1 // Start of the current hunk
+		last  = -1 // Last changed line of the current hunk
+	)
+	for i, line := range lines {
+		if line.Kind == Equal {
+			continue
+		}
+		if start >= 0 && i-last > 2*context+1 {
+			hunks = append(hunks, lines[start:min(last+context+1, len(lines))])
+			start = -1
+		}
+		if start < 0 {
+			start = max(i-context, 0)
+		}
+		last = i
+	}
+	if start >= 0 {
+		hunks = append(hunks, lines[start:min(last+context+1, len(lines))])
+	}
+	return hunks
+}
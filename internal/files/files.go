@@ -16,6 +16,7 @@ var paths struct {
 	staticFiles         string
 	configPath          string
 	tokensJSON          string
+	apiTokensJSON       string
 	userCredentialsJSON string
 	categoriesJSON      string
 	interwikiJSON       string
@@ -39,6 +40,9 @@ func ConfigPath() string { return paths.configPath }
 // TokensJSON returns the path to the JSON user tokens storage.
 func TokensJSON() string { return paths.tokensJSON }
 
+// APITokensJSON returns the path to the JSON API tokens storage.
+func APITokensJSON() string { return paths.apiTokensJSON }
+
 // UserCredentialsJSON returns the path to the JSON user credentials storage.
 func UserCredentialsJSON() string { return paths.userCredentialsJSON }
 
@@ -80,6 +84,7 @@ func PrepareWikiRoot() error {
 	paths.userCredentialsJSON = filepath.Join(cfg.WikiDir, "users.json")
 
 	paths.tokensJSON = filepath.Join(paths.cacheDir, "tokens.json")
+	paths.apiTokensJSON = filepath.Join(paths.cacheDir, "api-tokens.json")
 	paths.categoriesJSON = filepath.Join(cfg.WikiDir, "categories.json")
 	paths.interwikiJSON = FileInRoot("interwiki.json")
 
@@ -0,0 +1,163 @@
+// Package leases keeps track of who is editing which hypha. Leases are soft: they do not forbid editing, they only warn others. They live in memory and are lost on restart.
+package leases
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bouncepaw/mycorrhiza/util"
+)
+
+// Duration is how long a lease lasts unless it is renewed.
+const Duration = 15 * time.Minute
+
+// anonymous is the username all anonymous users share. Their leases are told apart by IDs.
+const anonymous = "anon"
+
+// Lease says that the user is editing the hypha.
+type Lease struct {
+	// ID tells the leases on a hypha apart. For registered users, who have one lease per hypha, it is the username. Anonymous users get a random ID for every editor they open.
+	ID        string
+	HyphaName string
+	Username  string
+	// Since is when the user began editing, Until is when the lease expires.
+	Since time.Time
+	Until time.Time
+}
+
+// ErrTaken is returned by TryTake when someone else holds a lease on the hypha.
+var ErrTaken = errors.New("the hypha is being edited by someone else")
+
+var (
+	leaseMutex sync.Mutex
+	// hyphaName → lease ID → lease
+	leases = map[string]map[string]Lease{}
+)
+
+// Take gives the user a lease on the hypha, or renews the lease with the given ID. Anonymous users get a new lease if the ID is not theirs, registered users have one lease per hypha whatever the ID. Other leases on the hypha are returned.
+func Take(hyphaName, username, id string) (lease Lease, others []Lease) {
+	leaseMutex.Lock()
+	defer leaseMutex.Unlock()
+	id = idOf(username, id)
+	others = othersOf(hyphaName, id, time.Now())
+	return take(hyphaName, username, id), others
+}
+
+// TryTake is like Take, but it does not take the lease if someone else holds one. Then ErrTaken and their leases are returned.
+func TryTake(hyphaName, username, id string) (Lease, []Lease, error) {
+	leaseMutex.Lock()
+	defer leaseMutex.Unlock()
+	id = idOf(username, id)
+	if others := othersOf(hyphaName, id, time.Now()); len(others) > 0 {
+		return Lease{}, others, ErrTaken
+	}
+	return take(hyphaName, username, id), nil, nil
+}
+
+// Release removes the user's lease on the hypha. Anonymous users have to give the ID of their lease. It returns false if there was no such lease.
+func Release(hyphaName, username, id string) bool {
+	leaseMutex.Lock()
+	defer leaseMutex.Unlock()
+	id = idOf(username, id)
+	if id == "" {
+		return false
+	}
+	lease, ok := leases[hyphaName][id]
+	delete(leases[hyphaName], id)
+	if len(leases[hyphaName]) == 0 {
+		delete(leases, hyphaName)
+	}
+	return ok && lease.Until.After(time.Now())
+}
+
+// ReleaseAll removes all leases on the hypha. Call it when the hypha is deleted or renamed.
+func ReleaseAll(hyphaName string) {
+	leaseMutex.Lock()
+	defer leaseMutex.Unlock()
+	delete(leases, hyphaName)
+}
+
+// Of returns the active leases on the hypha, oldest first.
+func Of(hyphaName string) []Lease {
+	leaseMutex.Lock()
+	defer leaseMutex.Unlock()
+	return othersOf(hyphaName, "", time.Now())
+}
+
+// OthersOf returns the active leases on the hypha other than the user's one with the given ID, oldest first.
+func OthersOf(hyphaName, username, id string) []Lease {
+	leaseMutex.Lock()
+	defer leaseMutex.Unlock()
+	return othersOf(hyphaName, idOf(username, id), time.Now())
+}
+
+// All returns all active leases, sorted by hypha name, then oldest first.
+func All() []Lease {
+	leaseMutex.Lock()
+	defer leaseMutex.Unlock()
+	var (
+		now    = time.Now()
+		result []Lease
+	)
+	for hyphaName := range leases {
+		result = append(result, othersOf(hyphaName, "", now)...)
+	}
+	slices.SortStableFunc(result, func(a, b Lease) int {
+		return strings.Compare(a.HyphaName, b.HyphaName)
+	})
+	return result
+}
+
+// idOf returns the ID of the user's lease. For anonymous users, it is the given ID if it looks like one of theirs, or an empty string.
+func idOf(username, id string) string {
+	if username != anonymous {
+		return username
+	}
+	if !strings.HasPrefix(id, anonymous+"/") {
+		return ""
+	}
+	return id
+}
+
+// take takes the lease with the ID, an empty ID means a new anonymous lease. Call it with leaseMutex locked.
+func take(hyphaName, username, id string) Lease {
+	now := time.Now()
+	lease, ok := leases[hyphaName][id]
+	if !ok || !lease.Until.After(now) {
+		if id == "" {
+			// Usernames cannot have slashes, so the ID is never someone's name.
+			random, _ := util.RandomString(8)
+			id = anonymous + "/" + random
+		}
+		lease = Lease{ID: id, HyphaName: hyphaName, Username: username, Since: now}
+	}
+	lease.Until = now.Add(Duration)
+	if leases[hyphaName] == nil {
+		leases[hyphaName] = map[string]Lease{}
+	}
+	leases[hyphaName][id] = lease
+	return lease
+}
+
+// othersOf returns the leases on the hypha but the one with the ID. It also forgets the expired leases on the hypha. Call it with leaseMutex locked.
+func othersOf(hyphaName, id string, now time.Time) []Lease {
+	var result []Lease
+	for leaseID, lease := range leases[hyphaName] {
+		switch {
+		case !lease.Until.After(now):
+			delete(leases[hyphaName], leaseID)
+		case leaseID != id:
+			result = append(result, lease)
+		}
+	}
+	if len(leases[hyphaName]) == 0 {
+		delete(leases, hyphaName)
+	}
+	slices.SortFunc(result, func(a, b Lease) int {
+		return a.Since.Compare(b.Since)
+	})
+	return result
+}
@@ -0,0 +1,154 @@
+package mdrenderer
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/bouncepaw/mycorrhiza/util"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// ExtractHyphaLinks returns canonical names of the hyphae linked from the Markdown source of the given hypha. Wiki-links, /hypha/ links and bare relative links are collected.
+func ExtractHyphaLinks(hyphaName string, source []byte) []string {
+	var (
+		result []string
+		base   = &url.URL{Path: "/hypha/" + util.CanonicalName(hyphaName)}
+	)
+	_ = ast.Walk(parseHypha(hyphaName, source), func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := node.(type) {
+		case *WikiLink:
+			if !node.External {
+				result = append(result, node.Target)
+			}
+		case *ast.Link:
+			if name, ok := localHyphaTarget(base, string(node.Destination)); ok {
+				result = append(result, name)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return result
+}
+
+// localHyphaTarget resolves a link destination the way a browser would from the hypha page at base and returns the name of the hypha it points to, if any.
+func localHyphaTarget(base *url.URL, destination string) (string, bool) {
+	ref, err := url.Parse(destination)
+	if err != nil || ref.Scheme != "" || ref.Host != "" || ref.Path == "" {
+		return "", false
+	}
+	name, found := strings.CutPrefix(base.ResolveReference(ref).Path, "/hypha/")
+	if !found || name == "" {
+		return "", false
+	}
+	return util.CanonicalName(name), true
+}
+
+// parseHypha parses the Markdown source of the given hypha into a goldmark AST.
+func parseHypha(hyphaName string, source []byte) ast.Node {
+	pc := parser.NewContext()
+	pc.Set(hyphaNameKey, hyphaName)
+	return Markdown.Parser().Parse(text.NewReader(source), parser.WithContext(pc))
+}
+
+var (
+	wikiLinkTargetRe      = regexp.MustCompile(`\[\[([^\[\]|\n]+)(?:\|[^\[\]\n]*)?\]\]`)
+	transclusionTargetRe  = regexp.MustCompile(`(?m)^<=([^|\n]+)`)
+	inlineDestinationRe   = regexp.MustCompile(`\]\([ \t]*(<[^<>\n]*>|[^\s()<>]+)`)
+	referenceDefinitionRe = regexp.MustCompile(`(?m)^ {0,3}\[[^\]\n]+\]:[ \t]*(<[^<>\n]*>|\S+)`)
+)
+
+// RewriteHyphaLinks returns the Markdown source with local links to the renamed hyphae pointing to their new names. The map is from old canonical names to new ones. The hypha itself might have been renamed from hyphaName to newHyphaName; relative links that still work after that are left as they are. Code is not touched.
+func RewriteHyphaLinks(hyphaName, newHyphaName string, source []byte, renamed map[string]string) []byte {
+	var (
+		code    = codeRanges(parseHypha(hyphaName, source), source)
+		oldBase = &url.URL{Path: "/hypha/" + util.CanonicalName(hyphaName)}
+		newBase = &url.URL{Path: "/hypha/" + util.CanonicalName(newHyphaName)}
+	)
+	rewriteWikiTarget := func(target string) (string, bool) {
+		if strings.Contains(target, "://") {
+			return "", false
+		}
+		name, anchor := resolveWikiLinkTarget(hyphaName, target)
+		newName, ok := renamed[name]
+		if !ok {
+			return "", false
+		}
+		if stillName, _ := resolveWikiLinkTarget(newHyphaName, target); stillName == newName {
+			return "", false
+		}
+		if anchor != "" {
+			newName += "#" + anchor
+		}
+		return newName, true
+	}
+	rewriteDestination := func(destination string) (string, bool) {
+		inner, bracketed := strings.CutPrefix(destination, "<")
+		if bracketed {
+			inner = strings.TrimSuffix(inner, ">")
+		}
+		name, ok := localHyphaTarget(oldBase, inner)
+		if !ok {
+			return "", false
+		}
+		newName, ok := renamed[name]
+		if !ok {
+			return "", false
+		}
+		if stillName, _ := localHyphaTarget(newBase, inner); stillName == newName {
+			return "", false
+		}
+		ref, _ := url.Parse(inner)
+		newDestination := (&url.URL{Path: "/hypha/" + newName, Fragment: ref.Fragment}).String()
+		if bracketed {
+			newDestination = "<" + newDestination + ">"
+		}
+		return newDestination, true
+	}
+
+	var edits []util.TextEdit
+	for _, pattern := range []struct {
+		re      *regexp.Regexp
+		rewrite func(string) (string, bool)
+	}{
+		{wikiLinkTargetRe, rewriteWikiTarget},
+		{transclusionTargetRe, rewriteWikiTarget},
+		{inlineDestinationRe, rewriteDestination},
+		{referenceDefinitionRe, rewriteDestination},
+	} {
+		edits = append(edits, util.SubmatchEdits(source, pattern.re, code, pattern.rewrite)...)
+	}
+	return util.ApplyTextEdits(source, edits)
+}
+
+// codeRanges returns the byte ranges of code and raw HTML blocks and code spans. Link syntax is not a link there.
+func codeRanges(doc ast.Node, source []byte) (ranges [][2]int) {
+	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := node.(type) {
+		case *ast.FencedCodeBlock, *ast.CodeBlock, *ast.HTMLBlock:
+			lines := node.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				line := lines.At(i)
+				ranges = append(ranges, [2]int{line.Start, line.Stop})
+			}
+		case *ast.CodeSpan:
+			for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+				if text, ok := child.(*ast.Text); ok {
+					ranges = append(ranges, [2]int{text.Segment.Start, text.Segment.Stop})
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return ranges
+}
@@ -0,0 +1,98 @@
+package mdrenderer
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/bouncepaw/mycorrhiza/internal/cfg"
+	"github.com/bouncepaw/mycorrhiza/util"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// OpenGraph returns OpenGraph meta tags for the Markdown source of the given hypha. They are the same as Mycomarkup makes: the description is the first root paragraph, the image is the first image.
+func OpenGraph(hyphaName string, source []byte) string {
+	var (
+		doc         = parseHypha(hyphaName, source)
+		base        = &url.URL{Path: "/hypha/" + util.CanonicalName(hyphaName)}
+		imageURL    = "/favicon.ico"
+		description string
+	)
+
+	for child := doc.FirstChild(); child != nil; child = child.NextSibling() {
+		if child.Kind() == ast.KindParagraph {
+			description = PlainText(child, source)
+			break
+		}
+		switch child.Kind() {
+		case ast.KindHeading, ast.KindFencedCodeBlock, ast.KindCodeBlock: // Let's have at least something if there is no paragraph.
+			if description == "" {
+				description = PlainText(child, source)
+			}
+		}
+	}
+
+	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		img, ok := node.(*ast.Image)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		if ref, err := url.Parse(string(img.Destination)); err == nil {
+			if ref.Scheme != "" {
+				imageURL = ref.String()
+			} else {
+				imageURL = cfg.URL + base.ResolveReference(ref).String()
+			}
+		}
+		return ast.WalkStop, nil
+	})
+
+	return strings.Join([]string{
+		ogTag("title", util.BeautifulName(hyphaName)),
+		ogTag("type", "article"),
+		ogTag("image", imageURL),
+		ogTag("url", cfg.URL+"/hypha/"+util.CanonicalName(hyphaName)),
+		ogTag("determiner", ""),
+		ogTag("description", description),
+	}, "\n")
+}
+
+// PlainText returns the text of the node with all markup stripped.
+func PlainText(node ast.Node, source []byte) string {
+	var buf strings.Builder
+	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			if n.Type() == ast.TypeBlock && buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			buf.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				buf.WriteString("\n")
+			}
+		case *ast.String:
+			buf.Write(n.Value)
+		case *ast.AutoLink:
+			buf.Write(n.Label(source))
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			lines := n.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				line := lines.At(i)
+				buf.Write(line.Value(source))
+			}
+		case *ast.RawHTML, *ast.HTMLBlock:
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(buf.String())
+}
+
+func ogTag(property, content string) string {
+	return fmt.Sprintf(`<meta property="og:%s" content="%s"/>`, property, html.EscapeString(content))
+}
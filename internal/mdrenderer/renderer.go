@@ -20,6 +20,8 @@ func init() {
 			extension.Strikethrough,
 			extension.Linkify,
 			extension.TaskList,
+			WikiLinks,
+			Transclusions,
 		),
 		goldmark.WithParserOptions(
 			parser.WithAutoHeadingID(),
@@ -33,8 +35,16 @@ func init() {
 
 // Render converts Markdown to HTML
 func Render(source []byte) (string, error) {
+	return RenderHypha("", source)
+}
+
+// RenderHypha converts Markdown of the given hypha to HTML. Relative wiki-links are resolved against hyphaName.
+func RenderHypha(hyphaName string, source []byte) (string, error) {
+	pc := parser.NewContext()
+	pc.Set(hyphaNameKey, hyphaName)
+
 	var buf bytes.Buffer
-	if err := Markdown.Convert(source, &buf); err != nil {
+	if err := Markdown.Convert(source, &buf, parser.WithContext(pc)); err != nil {
 		return "", err
 	}
 	return buf.String(), nil
@@ -0,0 +1,249 @@
+package mdrenderer
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/mycoopts"
+	"github.com/bouncepaw/mycorrhiza/util"
+
+	"git.sr.ht/~bouncepaw/mycomarkup/v5"
+	"git.sr.ht/~bouncepaw/mycomarkup/v5/blocks"
+	"git.sr.ht/~bouncepaw/mycomarkup/v5/genhtml"
+	"git.sr.ht/~bouncepaw/mycomarkup/v5/mycocontext"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	goldutil "github.com/yuin/goldmark/util"
+)
+
+// transclusionDepthKey holds how deep in transclusions the hypha being rendered is.
+var transclusionDepthKey = parser.NewContextKey()
+
+// maxTransclusionDepth is the same as in Mycomarkup.
+const maxTransclusionDepth = 3
+
+// KindTransclusion is the node kind of Transclusion.
+var KindTransclusion = ast.NewNodeKind("Transclusion")
+
+// Transclusion is a <= target | selector line, same as in Mycomarkup.
+type Transclusion struct {
+	ast.BaseBlock
+
+	// Line is everything after <=.
+	Line string
+	// HyphaName is the name of the hypha the transclusion is in.
+	HyphaName string
+	// Depth is how deep in transclusions the block is.
+	Depth int
+}
+
+// Kind implements ast.Node.
+func (n *Transclusion) Kind() ast.NodeKind { return KindTransclusion }
+
+// Dump implements ast.Node.
+func (n *Transclusion) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"Line": n.Line,
+	}, nil)
+}
+
+// Target returns the raw target of the transclusion.
+func (n *Transclusion) Target() string {
+	target, _, _ := strings.Cut(n.Line, "|")
+	return strings.TrimSpace(target)
+}
+
+// Selector returns the transclusion selector, parsed like in Mycomarkup.
+func (n *Transclusion) Selector() (selector blocks.TransclusionSelector, blend bool) {
+	_, s, found := strings.Cut(n.Line, "|")
+	if !found {
+		return blocks.SelectorOverview, false
+	}
+	blend = strings.Contains(s, "blend")
+	switch {
+	case strings.Contains(s, "full"):
+		return blocks.SelectorFull, blend
+	case strings.Contains(s, "text"):
+		return blocks.SelectorText, blend
+	case strings.Contains(s, "overview"):
+		return blocks.SelectorOverview, blend
+	case strings.Contains(s, "description"):
+		if strings.Contains(s, "attachment") {
+			return blocks.SelectorOverview, blend
+		}
+		return blocks.SelectorDescription, blend
+	case strings.Contains(s, "attachment"):
+		return blocks.SelectorAttachment, blend
+	default:
+		return blocks.SelectorOverview, blend
+	}
+}
+
+// markdownTarget returns the canonical name of the transcluded hypha if it is a Markdown hypha.
+func (n *Transclusion) markdownTarget() (string, bool) {
+	target := n.Target()
+	if target == "" || strings.ContainsAny(target, ":>") || strings.HasPrefix(target, "/") {
+		return "", false
+	}
+	name, _ := resolveWikiLinkTarget(n.HyphaName, target)
+	h, ok := hyphae.ByName(name).(hyphae.ExistingHypha)
+	if !ok || !h.HasTextFile() || hyphae.DetectTextFormat(h.TextFilePath()) != hyphae.FormatMarkdown {
+		return "", false
+	}
+	return name, true
+}
+
+type transclusionParser struct{}
+
+func (p *transclusionParser) Trigger() []byte {
+	return []byte{'<'}
+}
+
+func (p *transclusionParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	if !bytes.HasPrefix(line, []byte("<=")) {
+		return nil, parser.NoChildren
+	}
+	node := &Transclusion{
+		Line: strings.TrimSpace(string(line[2:])),
+	}
+	if name, ok := pc.Get(hyphaNameKey).(string); ok {
+		node.HyphaName = name
+	}
+	if depth, ok := pc.Get(transclusionDepthKey).(int); ok {
+		node.Depth = depth
+	}
+	reader.Advance(segment.Len() - 1)
+	return node, parser.NoChildren
+}
+
+func (p *transclusionParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	return parser.Close
+}
+
+func (p *transclusionParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (p *transclusionParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (p *transclusionParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+type transclusionRenderer struct{}
+
+func (r *transclusionRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindTransclusion, r.renderTransclusion)
+}
+
+func (r *transclusionRenderer) renderTransclusion(w goldutil.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	xcl := node.(*Transclusion)
+
+	name, ok := xcl.markdownTarget()
+	if !ok {
+		// Mycomarkup knows how to transclude everything else, and how to report errors.
+		ctx, _ := mycocontext.ContextFromStringInput("<= "+xcl.Line, mycoopts.MarkupOptions(xcl.HyphaName))
+		for _, block := range mycomarkup.BlockTree(ctx) {
+			_, _ = w.WriteString(genhtml.BlockToTag(ctx, block).String() + "\n")
+		}
+		return ast.WalkContinue, nil
+	}
+	if xcl.Depth > maxTransclusionDepth {
+		_, _ = w.WriteString(transclusionFailure("not-exists", "Transclusion depth limit"))
+		return ast.WalkContinue, nil
+	}
+
+	rawText, binaryHTML, err := mycoopts.MarkupOptions(name).HyphaHTMLData(name)
+	if err != nil {
+		_, _ = w.WriteString(transclusionFailure("not-exists", fmt.Sprintf(
+			`Cannot transclude hypha <a class="wikilink wikilink_new" href="/hypha/%[1]s">%[1]s</a> because it does not exist`,
+			html.EscapeString(name))))
+		return ast.WalkContinue, nil
+	}
+
+	var (
+		selector, blend = xcl.Selector()
+		rawSource       = []byte(rawText)
+		collected       []ast.Node
+		content         bytes.Buffer
+	)
+	pc := parser.NewContext()
+	pc.Set(hyphaNameKey, name)
+	pc.Set(transclusionDepthKey, xcl.Depth+1)
+	doc := Markdown.Parser().Parse(text.NewReader(rawSource), parser.WithContext(pc))
+
+	switch selector {
+	case blocks.SelectorText, blocks.SelectorFull:
+		for child := doc.FirstChild(); child != nil; child = child.NextSibling() {
+			collected = append(collected, child)
+		}
+	case blocks.SelectorOverview, blocks.SelectorDescription:
+		for child := doc.FirstChild(); child != nil; child = child.NextSibling() {
+			if child.Kind() == ast.KindParagraph {
+				collected = append(collected, child)
+				break
+			}
+		}
+	}
+
+	if len(collected) == 0 {
+		link := fmt.Sprintf(`<a href="/hypha/%s" class="wikilink">%s</a>`,
+			html.EscapeString(name), html.EscapeString(util.BeautifulName(name)))
+		switch selector {
+		case blocks.SelectorDescription:
+			_, _ = w.WriteString(transclusionFailure("no-description", "Hypha "+link+" has no description"))
+			return ast.WalkContinue, nil
+		case blocks.SelectorText:
+			_, _ = w.WriteString(transclusionFailure("no-text", "Hypha "+link+" has no text"))
+			return ast.WalkContinue, nil
+		}
+	}
+
+	if selector == blocks.SelectorAttachment || selector == blocks.SelectorFull || selector == blocks.SelectorOverview {
+		content.WriteString(binaryHTML)
+	}
+	for _, child := range collected {
+		if err := Markdown.Renderer().Render(&content, rawSource, child); err != nil {
+			return ast.WalkStop, err
+		}
+	}
+
+	mode := "stand-out"
+	if blend {
+		mode = "blend"
+	}
+	_, _ = fmt.Fprintf(w,
+		`<section class="transclusion transclusion_ok transclusion_%s"><a class="transclusion__link" href="/hypha/%s">%s</a><div class="transclusion__content">%s</div></section>`+"\n",
+		mode, html.EscapeString(name), html.EscapeString(name), content.String())
+	return ast.WalkContinue, nil
+}
+
+// transclusionFailure returns the same markup for failed transclusions as Mycomarkup does.
+func transclusionFailure(reason, message string) string {
+	return fmt.Sprintf(`<section class="transclusion transclusion_failed transclusion_%s"><p>%s</p></section>`+"\n", reason, message)
+}
+
+type transclusionExtension struct{}
+
+// Transclusions is a goldmark extension that adds Mycomarkup-style <= transclusions. Markdown hyphae are transcluded as Markdown, everything else is left to Mycomarkup.
+var Transclusions goldmark.Extender = &transclusionExtension{}
+
+func (e *transclusionExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithBlockParsers(
+		// Before the HTML block parser, which has priority 900.
+		goldutil.Prioritized(&transclusionParser{}, 850),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		goldutil.Prioritized(&transclusionRenderer{}, 850),
+	))
+}
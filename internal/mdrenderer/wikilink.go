@@ -0,0 +1,191 @@
+package mdrenderer
+
+import (
+	"bytes"
+	"path"
+	"strings"
+
+	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/util"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	goldutil "github.com/yuin/goldmark/util"
+)
+
+// hyphaNameKey holds the name of the hypha being rendered. Relative wiki-links are resolved against it.
+var hyphaNameKey = parser.NewContextKey()
+
+// KindWikiLink is the node kind of WikiLink.
+var KindWikiLink = ast.NewNodeKind("WikiLink")
+
+// WikiLink is a [[target|label]] link to a hypha or an external URL.
+type WikiLink struct {
+	ast.BaseInline
+
+	// Target is the canonical name of the linked hypha, or the URL for external links.
+	Target string
+	// Anchor is the part after #, if any.
+	Anchor string
+	// Destination is the target as written in the source, with the anchor. Relative targets stay relative.
+	Destination string
+	// External is true for links to URLs.
+	External bool
+}
+
+// Kind implements ast.Node.
+func (n *WikiLink) Kind() ast.NodeKind { return KindWikiLink }
+
+// Dump implements ast.Node.
+func (n *WikiLink) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"Target": n.Target,
+		"Anchor": n.Anchor,
+	}, nil)
+}
+
+// Href returns the value of the href attribute for the link.
+func (n *WikiLink) Href() string {
+	if n.External {
+		return n.Target
+	}
+	href := "/hypha/" + n.Target
+	if n.Anchor != "" {
+		href += "#" + n.Anchor
+	}
+	return href
+}
+
+// resolveWikiLinkTarget turns the raw target of a wiki-link into a canonical hypha name and an anchor. Relative targets are resolved against hyphaName, like in Mycomarkup.
+func resolveWikiLinkTarget(hyphaName, target string) (name, anchor string) {
+	switch {
+	case target == "..":
+		return util.CanonicalName(path.Dir(hyphaName)), ""
+	case strings.HasPrefix(target, "./"):
+		target = path.Join(hyphaName, target[2:])
+	case strings.HasPrefix(target, "../"):
+		target = path.Join(path.Dir(hyphaName), target[3:])
+	}
+	if hashPos := strings.IndexRune(target, '#'); hashPos != -1 {
+		target, anchor = target[:hashPos], target[hashPos+1:]
+	}
+	return util.CanonicalName(target), anchor
+}
+
+type wikiLinkParser struct{}
+
+func (p *wikiLinkParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	if !bytes.HasPrefix(line, []byte("[[")) {
+		return nil
+	}
+	end := bytes.Index(line, []byte("]]"))
+	if end < 0 {
+		return nil
+	}
+	inner := line[2:end]
+	if bytes.ContainsAny(inner, "[]") {
+		return nil
+	}
+
+	var (
+		rawTarget = inner
+		labelFrom = 2
+		labelTo   = end
+	)
+	if bar := bytes.IndexByte(inner, '|'); bar != -1 {
+		rawTarget = inner[:bar]
+		labelFrom = 2 + bar + 1
+	}
+	target := strings.TrimSpace(string(rawTarget))
+	if target == "" {
+		return nil
+	}
+
+	link := &WikiLink{Destination: target}
+	if strings.Contains(target, "://") {
+		link.Target, link.External = target, true
+	} else {
+		var hyphaName string
+		if name, ok := pc.Get(hyphaNameKey).(string); ok {
+			hyphaName = name
+		}
+		link.Target, link.Anchor = resolveWikiLinkTarget(hyphaName, target)
+		if link.Target == "" {
+			return nil
+		}
+	}
+
+	// Without a label, the target is displayed as written.
+	labelSegment := trimSegment(block.Source(), text.NewSegment(segment.Start+labelFrom, segment.Start+labelTo))
+	if labelFrom == 2 || labelSegment.IsEmpty() {
+		labelSegment = trimSegment(block.Source(), text.NewSegment(segment.Start+2, segment.Start+end))
+	}
+	link.AppendChild(link, ast.NewTextSegment(labelSegment))
+
+	block.Advance(end + 2)
+	return link
+}
+
+func trimSegment(source []byte, segment text.Segment) text.Segment {
+	segment = segment.TrimLeftSpace(source)
+	return segment.TrimRightSpace(source)
+}
+
+type wikiLinkRenderer struct{}
+
+func (r *wikiLinkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindWikiLink, r.renderWikiLink)
+}
+
+func (r *wikiLinkRenderer) renderWikiLink(w goldutil.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	link := node.(*WikiLink)
+	if !entering {
+		_, _ = w.WriteString("</a>")
+		return ast.WalkContinue, nil
+	}
+
+	classes := "wikilink wikilink_internal"
+	switch {
+	case link.External:
+		classes = "wikilink wikilink_external"
+	case !hyphaExists(link.Target):
+		classes += " wikilink_new"
+	}
+
+	_, _ = w.WriteString(`<a class="` + classes + `" href="`)
+	// Like goldmark does for usual links, javascript: and other dangerous URLs are left out.
+	if href := []byte(link.Href()); !link.External || !html.IsDangerousURL(href) {
+		_, _ = w.Write(goldutil.EscapeHTML(goldutil.URLEscape(href, true)))
+	}
+	_, _ = w.WriteString(`">`)
+	return ast.WalkContinue, nil
+}
+
+func hyphaExists(name string) bool {
+	_, empty := hyphae.ByName(name).(*hyphae.EmptyHypha)
+	return !empty
+}
+
+type wikiLinkExtension struct{}
+
+// WikiLinks is a goldmark extension that adds Mycomarkup-style [[hypha|label]] links with red links for missing hyphae.
+var WikiLinks goldmark.Extender = &wikiLinkExtension{}
+
+func (e *wikiLinkExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(
+		// Before the standard link parser, which has priority 200.
+		goldutil.Prioritized(&wikiLinkParser{}, 199),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		goldutil.Prioritized(&wikiLinkRenderer{}, 199),
+	))
+}
@@ -0,0 +1,55 @@
+package mdrenderer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWikiLink_DangerousURLs(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"javascript", "[[javascript://%0Aalert(document.cookie)|click]]"},
+		{"javascript in upper case", "[[JavaScript://%0Aalert(1)|click]]"},
+		{"vbscript", "[[vbscript://msgbox(1)|click]]"},
+		{"data", "[[data://text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==|click]]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render([]byte(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(got, `href=""`) {
+				t.Errorf("Render(%q) = %q, want an empty href", tt.input, got)
+			}
+		})
+	}
+}
+
+func TestWikiLink_SafeURLs(t *testing.T) {
+	tests := []struct {
+		input    string
+		wantHref string
+	}{
+		{"[[https://example.org/page|site]]", `href="https://example.org/page"`},
+		{"[[gemini://example.org|capsule]]", `href="gemini://example.org"`},
+		{"[[Some Hypha]]", `href="/hypha/some_hypha"`},
+		// Without //, the target is a hypha name, so the link stays inside the wiki.
+		{"[[javascript:alert(1)|click]]", `href="/hypha/javascriptalert(1)"`},
+		{"[[vbscript:msgbox(1)|click]]", `href="/hypha/vbscriptmsgbox(1)"`},
+		{"[[data:text/html,hi|click]]", `href="/hypha/datatext/html,hi"`},
+	}
+
+	for _, tt := range tests {
+		got, err := Render([]byte(tt.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(got, tt.wantHref) {
+			t.Errorf("Render(%q) = %q, want %s", tt.input, got, tt.wantHref)
+		}
+	}
+}
@@ -17,7 +17,7 @@ func RenderHyphaContent(h hyphae.ExistingHypha, content string, hyphaName string
 
 	switch format {
 	case hyphae.FormatMarkdown:
-		html, err := mdrenderer.Render([]byte(content))
+		html, err := mdrenderer.RenderHypha(hyphaName, []byte(content))
 		if err != nil {
 			return "", err
 		}
@@ -40,7 +40,7 @@ func RenderHyphaContent(h hyphae.ExistingHypha, content string, hyphaName string
 func RenderForPreview(content string, format hyphae.TextFormat, hyphaName string) (template.HTML, error) {
 	switch format {
 	case hyphae.FormatMarkdown:
-		html, err := mdrenderer.Render([]byte(content))
+		html, err := mdrenderer.RenderHypha(hyphaName, []byte(content))
 		if err != nil {
 			return "", err
 		}
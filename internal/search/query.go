@@ -0,0 +1,315 @@
+package search
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bouncepaw/mycorrhiza/history"
+	"github.com/bouncepaw/mycorrhiza/internal/categories"
+	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/util"
+)
+
+// SortOrder is the order of search results.
+type SortOrder int
+
+const (
+	// SortByRelevance puts the most relevant hyphae first.
+	SortByRelevance SortOrder = iota
+	// SortByName sorts hyphae like hyphae.PathographicSort does.
+	SortByName
+	// SortByChange puts the most recently changed hyphae first.
+	SortByChange
+)
+
+// Query is a parsed search query. See ParseQuery for the syntax.
+type Query struct {
+	// Terms must all be in the hypha.
+	Terms []string
+	// Phrases must all be in the hypha, word by word. They are stored as lists of terms.
+	Phrases [][]string
+	// ExcludedTerms and ExcludedPhrases must not be in the hypha.
+	ExcludedTerms   []string
+	ExcludedPhrases [][]string
+
+	// The hypha must be in any of the Categories, have any of the Prefixes and have its text in any of the Formats, if set.
+	Categories []string
+	Prefixes   []string
+	Formats    []hyphae.TextFormat
+	// The hypha must have been changed by any of the Authors, if set.
+	Authors []string
+	// The last change of the hypha must be in [ChangedAfter, ChangedBefore), if set.
+	ChangedAfter, ChangedBefore time.Time
+
+	Sort SortOrder
+}
+
+// ParseQuery parses a query like this:
+//
+//	category:ops prefix:runbooks/ format:md author:alice changed:>2026-01-01 "exact phrase" -excluded sort:name
+//
+// Words and "quoted phrases" must be in the hypha's name or text. With a minus before them, they must not. Filters can be repeated; a hypha matches a repeated filter if it matches any of its values. Filter values can be quoted too. The changed filter accepts dates in the YYYY-MM-DD format with an optional >, >=, < or <= before them. The sort filter is relevance, name or changed.
+func ParseQuery(raw string) (Query, error) {
+	var q Query
+	for _, token := range splitQuery(raw) {
+		word, excluded := strings.CutPrefix(token, "-")
+		if excluded && word == "" {
+			continue
+		}
+
+		if phrase, quoted := unquote(word); quoted {
+			terms := Terms(phrase)
+			switch {
+			case len(terms) == 0:
+			case excluded:
+				q.ExcludedPhrases = append(q.ExcludedPhrases, terms)
+			default:
+				q.Phrases = append(q.Phrases, terms)
+			}
+			continue
+		}
+
+		key, value, isFilter := strings.Cut(word, ":")
+		if isFilter && !excluded && value != "" {
+			handled, err := q.addFilter(strings.ToLower(key), value)
+			if err != nil {
+				return Query{}, err
+			}
+			if handled {
+				continue
+			}
+		}
+
+		if excluded {
+			q.ExcludedTerms = append(q.ExcludedTerms, Terms(word)...)
+		} else {
+			q.Terms = append(q.Terms, Terms(word)...)
+		}
+	}
+	return q, nil
+}
+
+// addFilter adds the filter to the query. If the key is not a known filter, nothing is done and false is returned.
+func (q *Query) addFilter(key, value string) (bool, error) {
+	if unquoted, quoted := unquote(value); quoted {
+		value = unquoted
+	}
+	switch key {
+	case "category":
+		q.Categories = append(q.Categories, util.CanonicalName(value))
+	case "prefix":
+		q.Prefixes = append(q.Prefixes, util.CanonicalName(value))
+	case "format":
+		switch strings.ToLower(value) {
+		case "md", "markdown":
+			q.Formats = append(q.Formats, hyphae.FormatMarkdown)
+		case "myco", "mycomarkup":
+			q.Formats = append(q.Formats, hyphae.FormatMycomarkup)
+		default:
+			return false, fmt.Errorf("unknown format ‘%s’, use md or myco", value)
+		}
+	case "author":
+		q.Authors = append(q.Authors, strings.ToLower(value))
+	case "changed":
+		return true, q.addChangedFilter(value)
+	case "sort":
+		switch strings.ToLower(value) {
+		case "relevance":
+			q.Sort = SortByRelevance
+		case "name":
+			q.Sort = SortByName
+		case "changed":
+			q.Sort = SortByChange
+		default:
+			return false, fmt.Errorf("unknown sort order ‘%s’, use relevance, name or changed", value)
+		}
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+func (q *Query) addChangedFilter(value string) error {
+	var operator string
+	for _, op := range []string{">=", "<=", ">", "<"} {
+		if rest, found := strings.CutPrefix(value, op); found {
+			operator, value = op, rest
+			break
+		}
+	}
+	day, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid date ‘%s’, use YYYY-MM-DD", value)
+	}
+	nextDay := day.AddDate(0, 0, 1)
+
+	var after, before time.Time
+	switch operator {
+	case ">":
+		after = nextDay
+	case ">=":
+		after = day
+	case "<":
+		before = day
+	case "<=":
+		before = nextDay
+	default:
+		after, before = day, nextDay
+	}
+	if !after.IsZero() && after.After(q.ChangedAfter) {
+		q.ChangedAfter = after
+	}
+	if !before.IsZero() && (q.ChangedBefore.IsZero() || before.Before(q.ChangedBefore)) {
+		q.ChangedBefore = before
+	}
+	return nil
+}
+
+// splitQuery splits the query by spaces. Quoted parts are not split, the quotes are kept.
+func splitQuery(raw string) (tokens []string) {
+	var (
+		token  strings.Builder
+		quoted bool
+	)
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			token.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteRune(r)
+		}
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens
+}
+
+func unquote(s string) (string, bool) {
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		return s[1 : len(s)-1], true
+	}
+	if strings.HasPrefix(s, `"`) { // Unclosed quote at the end of the query.
+		return s[1:], true
+	}
+	return s, false
+}
+
+// IsEmpty is true if the query does not restrict anything.
+func (q Query) IsEmpty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0 &&
+		len(q.ExcludedTerms) == 0 && len(q.ExcludedPhrases) == 0 &&
+		len(q.Categories) == 0 && len(q.Prefixes) == 0 && len(q.Formats) == 0 &&
+		len(q.Authors) == 0 && q.ChangedAfter.IsZero() && q.ChangedBefore.IsZero()
+}
+
+// positiveTerms returns all terms the hypha must have, including the terms of phrases, without repetitions.
+func (q Query) positiveTerms() []string {
+	var (
+		seen   = make(map[string]bool)
+		result []string
+	)
+	for _, term := range slices.Concat(q.Terms, flatten(q.Phrases)) {
+		if !seen[term] {
+			seen[term] = true
+			result = append(result, term)
+		}
+	}
+	return result
+}
+
+func flatten(phrases [][]string) (terms []string) {
+	for _, phrase := range phrases {
+		terms = append(terms, phrase...)
+	}
+	return terms
+}
+
+// matchesDocument checks the text conditions of the query that the inverted index cannot check. Lock the index before calling.
+func (q Query) matchesDocument(hyphaName string, doc *document) bool {
+	for _, term := range q.ExcludedTerms {
+		if doc.terms[term] > 0 {
+			return false
+		}
+	}
+	if len(q.Phrases) == 0 && len(q.ExcludedPhrases) == 0 {
+		return true
+	}
+	words := " " + strings.Join(append(Terms(hyphaName), Terms(doc.text)...), " ") + " "
+	hasPhrase := func(phrase []string) bool {
+		return strings.Contains(words, " "+strings.Join(phrase, " ")+" ")
+	}
+	for _, phrase := range q.Phrases {
+		if !hasPhrase(phrase) {
+			return false
+		}
+	}
+	for _, phrase := range q.ExcludedPhrases {
+		if hasPhrase(phrase) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesHypha checks the category, prefix and format filters.
+func (q Query) matchesHypha(hyphaName string) bool {
+	if len(q.Prefixes) > 0 && !hasAny(q.Prefixes, func(prefix string) bool {
+		return strings.HasPrefix(hyphaName, prefix)
+	}) {
+		return false
+	}
+	if len(q.Categories) > 0 && !hasAny(categories.CategoriesWithHypha(hyphaName), func(category string) bool {
+		return hasAny(q.Categories, func(wanted string) bool { return wanted == category })
+	}) {
+		return false
+	}
+	if len(q.Formats) > 0 {
+		h, ok := hyphae.ByName(hyphaName).(hyphae.ExistingHypha)
+		if !ok || !h.HasTextFile() {
+			return false
+		}
+		format := hyphae.DetectTextFormat(h.TextFilePath())
+		if !hasAny(q.Formats, func(wanted hyphae.TextFormat) bool { return wanted == format }) {
+			return false
+		}
+	}
+	return true
+}
+
+// needsHistory is true if the query cannot be answered without the history of hyphae.
+func (q Query) needsHistory() bool {
+	return len(q.Authors) > 0 || !q.ChangedAfter.IsZero() || !q.ChangedBefore.IsZero() || q.Sort == SortByChange
+}
+
+// matchesHistory checks the author and change time filters against the activity of the hypha. The activity is nil if the hypha has no history.
+func (q Query) matchesHistory(activity *history.Activity) bool {
+	if activity == nil {
+		return len(q.Authors) == 0 && q.ChangedAfter.IsZero() && q.ChangedBefore.IsZero()
+	}
+	if len(q.Authors) > 0 && !hasAny(activity.Authors, func(username string) bool {
+		return hasAny(q.Authors, func(author string) bool { return strings.EqualFold(username, author) })
+	}) {
+		return false
+	}
+	return (q.ChangedAfter.IsZero() || !activity.LastChange.Before(q.ChangedAfter)) &&
+		(q.ChangedBefore.IsZero() || activity.LastChange.Before(q.ChangedBefore))
+}
+
+func hasAny[T any](xs []T, predicate func(T) bool) bool {
+	for _, x := range xs {
+		if predicate(x) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,282 @@
+// Package search maintains the full-text index of hyphae and lets you query it.
+package search
+
+import (
+	"html/template"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bouncepaw/mycorrhiza/history"
+	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+)
+
+// document is an indexed hypha.
+type document struct {
+	text       string // Text with markup stripped, used for snippets.
+	titleTerms map[string]bool
+	terms      map[string]int // Term frequencies, including the title terms.
+	length     int
+}
+
+var (
+	indexMutex  sync.RWMutex
+	documents   = make(map[string]*document)
+	postings    = make(map[string]map[string]int) // Term → hypha name → term frequency.
+	totalLength int
+)
+
+// IndexHyphae builds the index anew from all existing hyphae. Call it after hyphae.Index.
+func IndexHyphae() {
+	indexMutex.Lock()
+	defer indexMutex.Unlock()
+	documents = make(map[string]*document)
+	postings = make(map[string]map[string]int)
+	totalLength = 0
+	for h := range hyphae.YieldExistingHyphae() {
+		add(h.CanonicalName(), newDocument(h.CanonicalName(), plainText(h)))
+	}
+}
+
+// UpdateAfterEdit is a creation/editing hook for the search index. Call it after the text or media of the hypha changed.
+func UpdateAfterEdit(h hyphae.Hypha) {
+	doc := newDocument(h.CanonicalName(), plainText(h))
+	indexMutex.Lock()
+	defer indexMutex.Unlock()
+	remove(h.CanonicalName())
+	add(h.CanonicalName(), doc)
+}
+
+// UpdateAfterRename is a renaming hook for the search index.
+func UpdateAfterRename(h hyphae.Hypha, oldName string) {
+	doc := newDocument(h.CanonicalName(), plainText(h))
+	indexMutex.Lock()
+	defer indexMutex.Unlock()
+	remove(oldName)
+	add(h.CanonicalName(), doc)
+}
+
+// UpdateAfterDelete is a deletion hook for the search index.
+func UpdateAfterDelete(hyphaName string) {
+	indexMutex.Lock()
+	defer indexMutex.Unlock()
+	remove(hyphaName)
+}
+
+func newDocument(hyphaName, text string) *document {
+	doc := &document{
+		text:       text,
+		titleTerms: make(map[string]bool),
+		terms:      make(map[string]int),
+	}
+	for _, term := range Terms(hyphaName) {
+		doc.titleTerms[term] = true
+		doc.terms[term]++
+		doc.length++
+	}
+	for _, term := range Terms(text) {
+		doc.terms[term]++
+		doc.length++
+	}
+	return doc
+}
+
+// add puts the document to the index. Lock the index before calling.
+func add(hyphaName string, doc *document) {
+	documents[hyphaName] = doc
+	totalLength += doc.length
+	for term, frequency := range doc.terms {
+		if _, exists := postings[term]; !exists {
+			postings[term] = make(map[string]int)
+		}
+		postings[term][hyphaName] = frequency
+	}
+}
+
+// remove deletes the document from the index, if it is there. Lock the index before calling.
+func remove(hyphaName string) {
+	doc, exists := documents[hyphaName]
+	if !exists {
+		return
+	}
+	for term := range doc.terms {
+		delete(postings[term], hyphaName)
+		if len(postings[term]) == 0 {
+			delete(postings, term)
+		}
+	}
+	totalLength -= doc.length
+	delete(documents, hyphaName)
+}
+
+// Result is a hypha found by Search.
+type Result struct {
+	HyphaName string
+	Score     float64
+	// Snippet is a piece of the hypha's text with the query terms highlighted.
+	Snippet template.HTML
+	// LastChange is the time of the last change of the hypha. It is only known if the query needed the history.
+	LastChange time.Time
+}
+
+// These are the usual Okapi BM25 parameters. Terms found in the hypha name weigh titleBoost times more.
+const (
+	k1         = 1.2
+	b          = 0.75
+	titleBoost = 2.0
+)
+
+// Search returns hyphae that match the query, in the order the query asks for. See ParseQuery for the query syntax.
+func Search(rawQuery string) ([]Result, error) {
+	query, err := ParseQuery(rawQuery)
+	if err != nil || query.IsEmpty() {
+		return nil, err
+	}
+
+	results := searchIndex(query)
+	if query.needsHistory() {
+		results = filterByHistory(query, results)
+	}
+
+	switch query.Sort {
+	case SortByRelevance:
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Score > results[j].Score
+		})
+	case SortByName:
+		results = sortByName(results)
+	case SortByChange:
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].LastChange.After(results[j].LastChange)
+		})
+	}
+	return results, nil
+}
+
+// searchIndex returns the hyphae that match all conditions of the query but the history ones, in alphabetical order.
+func searchIndex(query Query) (results []Result) {
+	indexMutex.RLock()
+	defer indexMutex.RUnlock()
+
+	var (
+		terms         = query.positiveTerms()
+		averageLength = float64(totalLength) / math.Max(float64(len(documents)), 1)
+	)
+	for hyphaName := range candidates(terms) {
+		doc := documents[hyphaName]
+		if !query.matchesDocument(hyphaName, doc) || !query.matchesHypha(hyphaName) {
+			continue
+		}
+		var score float64
+		for _, term := range terms {
+			var (
+				frequency = float64(postings[term][hyphaName])
+				idf       = inverseDocumentFrequency(term)
+				norm      = k1 * (1 - b + b*float64(doc.length)/averageLength)
+			)
+			score += idf * frequency * (k1 + 1) / (frequency + norm)
+			if doc.titleTerms[term] {
+				score += idf * titleBoost
+			}
+		}
+		results = append(results, Result{
+			HyphaName: hyphaName,
+			Score:     score,
+			Snippet:   snippet(doc.text, terms),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].HyphaName < results[j].HyphaName
+	})
+	return results
+}
+
+// filterByHistory leaves the results that match the history conditions of the query and sets their LastChange.
+func filterByHistory(query Query, results []Result) (filtered []Result) {
+	activities, err := history.Activities()
+	if err != nil {
+		slog.Error("Failed to get the activity of hyphae", "err", err)
+		return nil
+	}
+	for _, result := range results {
+		activity := activities[result.HyphaName]
+		if !query.matchesHistory(activity) {
+			continue
+		}
+		if activity != nil {
+			result.LastChange = activity.LastChange
+		}
+		filtered = append(filtered, result)
+	}
+	return filtered
+}
+
+func sortByName(results []Result) []Result {
+	var (
+		byName = make(map[string]Result)
+		names  = make(chan string)
+		sorted = hyphae.PathographicSort(names)
+	)
+	go func() {
+		for _, result := range results {
+			byName[result.HyphaName] = result
+			names <- result.HyphaName
+		}
+		close(names)
+	}()
+	results = results[:0]
+	for name := range sorted {
+		results = append(results, byName[name])
+	}
+	return results
+}
+
+// candidates returns the names of hyphae that have all the terms. Without terms, all hyphae are candidates. Lock the index before calling.
+func candidates(terms []string) map[string]bool {
+	result := make(map[string]bool)
+	if len(terms) == 0 {
+		for hyphaName := range documents {
+			result[hyphaName] = true
+		}
+		return result
+	}
+
+	// Start with the rarest term to keep the set small.
+	sorted := append([]string(nil), terms...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(postings[sorted[i]]) < len(postings[sorted[j]])
+	})
+
+	for hyphaName := range postings[sorted[0]] {
+		result[hyphaName] = true
+	}
+	for _, term := range sorted[1:] {
+		for hyphaName := range result {
+			if _, has := postings[term][hyphaName]; !has {
+				delete(result, hyphaName)
+			}
+		}
+	}
+	return result
+}
+
+// inverseDocumentFrequency is the BM25 variant of IDF, which is never negative. Lock the index before calling.
+func inverseDocumentFrequency(term string) float64 {
+	var (
+		n  = float64(len(documents))
+		df = float64(len(postings[term]))
+	)
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+// Terms splits the text into lowercase words. Underscores and slashes separate words, so hypha names are split into words too.
+func Terms(text string) []string {
+	var terms []string
+	for _, span := range termSpans(text) {
+		terms = append(terms, strings.ToLower(text[span[0]:span[1]]))
+	}
+	return terms
+}
@@ -0,0 +1,143 @@
+package search
+
+import (
+	"html"
+	"html/template"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/mdrenderer"
+	"github.com/bouncepaw/mycorrhiza/mycoopts"
+
+	"git.sr.ht/~bouncepaw/mycomarkup/v5"
+	"git.sr.ht/~bouncepaw/mycomarkup/v5/blocks"
+	"git.sr.ht/~bouncepaw/mycomarkup/v5/genhtml"
+	"git.sr.ht/~bouncepaw/mycomarkup/v5/mycocontext"
+	"github.com/yuin/goldmark/text"
+)
+
+var (
+	blockTagRe = regexp.MustCompile(`</?(p|br|li|dt|dd|td|th|tr|div|h\d|pre|blockquote|figcaption|caption)\b[^>]*>`)
+	tagRe      = regexp.MustCompile(`<[^>]*>`)
+	spaceRe    = regexp.MustCompile(`\s+`)
+)
+
+// plainText returns the text part of the hypha with markup stripped. Transclusions are not followed.
+func plainText(h hyphae.Hypha) string {
+	eh, ok := h.(hyphae.ExistingHypha)
+	if !ok || !eh.HasTextFile() {
+		return ""
+	}
+	contents, err := os.ReadFile(eh.TextFilePath())
+	if err != nil {
+		slog.Error("Failed to read file", "path", eh.TextFilePath(), "err", err, "hyphaName", h.CanonicalName())
+		return ""
+	}
+
+	if hyphae.DetectTextFormat(eh.TextFilePath()) == hyphae.FormatMarkdown {
+		doc := mdrenderer.Markdown.Parser().Parse(text.NewReader(contents))
+		return mdrenderer.PlainText(doc, contents)
+	}
+
+	var (
+		ctx, _ = mycocontext.ContextFromStringInput(string(contents), mycoopts.MarkupOptions(h.CanonicalName()))
+		buf    strings.Builder
+	)
+	for _, block := range mycomarkup.BlockTree(ctx) {
+		if _, ok := block.(blocks.Transclusion); ok {
+			continue
+		}
+		blockHTML := blockTagRe.ReplaceAllString(genhtml.BlockToTag(ctx, block).String(), " ")
+		buf.WriteString(html.UnescapeString(tagRe.ReplaceAllString(blockHTML, "")))
+		buf.WriteString("\n")
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func isTermRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// termSpans returns byte ranges of words in the text.
+func termSpans(text string) (spans [][2]int) {
+	start := -1
+	for i, r := range text {
+		switch {
+		case isTermRune(r) && start == -1:
+			start = i
+		case !isTermRune(r) && start != -1:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// How many bytes of text a snippet shows, and how many of them go before the first found term.
+const (
+	snippetLength = 240
+	snippetLead   = 60
+)
+
+// snippet returns a piece of the text around the first occurrence of any of the terms. The terms are highlighted with <mark>.
+func snippet(text string, terms []string) template.HTML {
+	var (
+		wanted = make(map[string]bool)
+		spans  = termSpans(text)
+		from   = 0
+	)
+	for _, term := range terms {
+		wanted[term] = true
+	}
+	for _, span := range spans {
+		if wanted[strings.ToLower(text[span[0]:span[1]])] {
+			from = max(0, span[0]-snippetLead)
+			break
+		}
+	}
+	to := min(len(text), from+snippetLength)
+	// Do not cut words and runes in half.
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	if from > 0 {
+		if space := strings.IndexFunc(text[from:to], unicode.IsSpace); space != -1 {
+			from += space
+		}
+	}
+	if to < len(text) {
+		if space := strings.LastIndexFunc(text[from:to], unicode.IsSpace); space > 0 {
+			to = from + space
+		}
+		for to > from && !utf8.RuneStart(text[to]) {
+			to--
+		}
+	}
+
+	var buf strings.Builder
+	if from > 0 {
+		buf.WriteString("… ")
+	}
+	last := from
+	for _, span := range spans {
+		if span[0] < from || span[1] > to || !wanted[strings.ToLower(text[span[0]:span[1]])] {
+			continue
+		}
+		buf.WriteString(template.HTMLEscapeString(text[last:span[0]]))
+		buf.WriteString("<mark>" + template.HTMLEscapeString(text[span[0]:span[1]]) + "</mark>")
+		last = span[1]
+	}
+	buf.WriteString(template.HTMLEscapeString(text[last:to]))
+	if to < len(text) {
+		buf.WriteString(" …")
+	}
+	return template.HTML(strings.TrimSpace(spaceRe.ReplaceAllString(buf.String(), " ")))
+}
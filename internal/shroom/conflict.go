@@ -0,0 +1,111 @@
+package shroom
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/bouncepaw/mycorrhiza/history"
+	"github.com/bouncepaw/mycorrhiza/internal/diff"
+	"github.com/bouncepaw/mycorrhiza/internal/files"
+	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+)
+
+// RevisionNone is the base revision of edits that began when the hypha had no text.
+const RevisionNone = "none"
+
+// EditConflictError is returned by UploadText when someone else changed the text of the hypha after the edit began. It has everything needed to resolve the conflict.
+type EditConflictError struct {
+	HyphaName string
+	// BaseRevision is the revision the edit began at, CurrentRevision is the revision of the text now.
+	BaseRevision, CurrentRevision string
+	// Base, Current and Yours are the texts at the base revision, at the current revision and in the rejected edit.
+	Base, Current, Yours string
+	// Merged is the result of merging Yours and Current. Conflicts is the number of places where they could not be merged, they are marked like git does.
+	Merged    string
+	Conflicts int
+}
+
+func (e *EditConflictError) Error() string {
+	return fmt.Sprintf("‘%s’ was changed by someone else since revision %s", e.HyphaName, e.BaseRevision)
+}
+
+// BaseRevision returns the revision of the hypha's text that an edit starting now is based on. It is RevisionNone if the hypha has no text, and empty if the revision is unknown, in which case conflicts are not checked.
+func BaseRevision(h hyphae.Hypha) string {
+	existing, ok := h.(hyphae.ExistingHypha)
+	if !ok || !existing.HasTextFile() {
+		return RevisionNone
+	}
+	rev, err := history.LastRevisionOf(existing.TextFilePath())
+	if err != nil {
+		slog.Error("Failed to find the last revision of hypha", "hyphaName", h.CanonicalName(), "err", err)
+		return ""
+	}
+	if rev == "" { // The text is not committed yet.
+		return ""
+	}
+	return rev
+}
+
+// checkEditConflict returns an *EditConflictError if the text of the hypha was changed since the base revision and the edit would overwrite that change. An empty base revision is never in conflict.
+func checkEditConflict(h hyphae.Hypha, baseRevision, currentText string, data []byte) error {
+	if baseRevision == "" {
+		return nil
+	}
+	return editConflict(h.CanonicalName(), baseRevision, BaseRevision(h), currentText, string(data), func() string {
+		return textAtRevision(h, baseRevision)
+	})
+}
+
+// editConflict is the part of checkEditConflict that does not look into the wiki. The base text is only read if the revisions differ.
+func editConflict(hyphaName, baseRevision, currentRevision, currentText, yours string, baseText func() string) error {
+	if history.SameRevision(baseRevision, currentRevision) || yours == currentText {
+		return nil
+	}
+
+	base := baseText()
+	if base == currentText {
+		return nil // Only the history moved, the text is the same.
+	}
+
+	merged, conflicts := diff.Merge(
+		diff.Lines(base), diff.Lines(yours), diff.Lines(currentText),
+		"your edit", "revision "+currentRevision,
+	)
+	mergedText := strings.Join(merged, "\n")
+	if len(merged) > 0 {
+		mergedText += "\n"
+	}
+	return &EditConflictError{
+		HyphaName:       hyphaName,
+		BaseRevision:    baseRevision,
+		CurrentRevision: currentRevision,
+		Base:            base,
+		Current:         currentText,
+		Yours:           yours,
+		Merged:          mergedText,
+		Conflicts:       conflicts,
+	}
+}
+
+// textAtRevision returns the text of the hypha at the revision. If it cannot be found, for example, because the hypha was renamed since then, the text is empty.
+func textAtRevision(h hyphae.Hypha, revision string) string {
+	if revision == RevisionNone {
+		return ""
+	}
+	var paths []string
+	if existing, ok := h.(hyphae.ExistingHypha); ok && existing.HasTextFile() {
+		paths = append(paths, existing.TextFilePath())
+	}
+	for _, format := range []hyphae.TextFormat{hyphae.FormatMycomarkup, hyphae.FormatMarkdown} {
+		paths = append(paths, filepath.Join(files.HyphaeDir(), h.CanonicalName()+hyphae.FormatExtension(format)))
+	}
+	for _, path := range paths {
+		if text, err := history.FileAtRevision(path, revision); err == nil {
+			return text
+		}
+	}
+	slog.Info("Could not find text of hypha at revision", "hyphaName", h.CanonicalName(), "revision", revision)
+	return ""
+}
@@ -0,0 +1,112 @@
+package shroom
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEditConflict(t *testing.T) {
+	tests := []struct {
+		name            string
+		baseRevision    string
+		currentRevision string
+		base            string
+		current         string
+		yours           string
+		wantConflict    bool
+		wantBaseRead    bool
+		wantMerged      string
+		wantConflicts   int
+	}{
+		{
+			name:            "same revision",
+			baseRevision:    "abc1234",
+			currentRevision: "abc1234def",
+			current:         "current\n",
+			yours:           "yours\n",
+		},
+		{
+			name:            "edit equals the current text",
+			baseRevision:    "abc1234",
+			currentRevision: "def5678",
+			current:         "same\n",
+			yours:           "same\n",
+		},
+		{
+			name:            "only the history moved",
+			baseRevision:    "abc1234",
+			currentRevision: "def5678",
+			base:            "text\n",
+			current:         "text\n",
+			yours:           "edited text\n",
+			wantBaseRead:    true,
+		},
+		{
+			name:            "changes merge cleanly",
+			baseRevision:    "abc1234",
+			currentRevision: "def5678",
+			base:            "a\nb\nc\n",
+			current:         "a\nb\nC\n",
+			yours:           "A\nb\nc\n",
+			wantConflict:    true,
+			wantBaseRead:    true,
+			wantMerged:      "A\nb\nC\n",
+		},
+		{
+			name:            "changes conflict",
+			baseRevision:    "abc1234",
+			currentRevision: "def5678",
+			base:            "a\n",
+			current:         "theirs\n",
+			yours:           "mine\n",
+			wantConflict:    true,
+			wantBaseRead:    true,
+			wantMerged:      "<<<<<<< your edit\nmine\n=======\ntheirs\n>>>>>>> revision def5678\n",
+			wantConflicts:   1,
+		},
+		{
+			name:            "hypha had no text",
+			baseRevision:    RevisionNone,
+			currentRevision: "def5678",
+			current:         "theirs\n",
+			yours:           "mine\n",
+			wantConflict:    true,
+			wantBaseRead:    true,
+			wantMerged:      "<<<<<<< your edit\nmine\n=======\ntheirs\n>>>>>>> revision def5678\n",
+			wantConflicts:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseRead := false
+			err := editConflict("hypha", tt.baseRevision, tt.currentRevision, tt.current, tt.yours, func() string {
+				baseRead = true
+				return tt.base
+			})
+			if baseRead != tt.wantBaseRead {
+				t.Errorf("base text read = %v, want %v", baseRead, tt.wantBaseRead)
+			}
+
+			var conflict *EditConflictError
+			if !errors.As(err, &conflict) {
+				if tt.wantConflict {
+					t.Fatalf("editConflict() = %v, want a conflict", err)
+				}
+				if err != nil {
+					t.Fatalf("editConflict() = %v, want nil", err)
+				}
+				return
+			}
+			if !tt.wantConflict {
+				t.Fatalf("editConflict() = %v, want nil", err)
+			}
+			if conflict.Merged != tt.wantMerged {
+				t.Errorf("Merged = %q, want %q", conflict.Merged, tt.wantMerged)
+			}
+			if conflict.Conflicts != tt.wantConflicts {
+				t.Errorf("Conflicts = %d, want %d", conflict.Conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package shroom
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bouncepaw/mycorrhiza/history"
+	"github.com/bouncepaw/mycorrhiza/internal/backlinks"
+	"github.com/bouncepaw/mycorrhiza/internal/converter"
+	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/search"
+	"github.com/bouncepaw/mycorrhiza/internal/user"
+)
+
+// ConvertPreview converts the text part of the hypha to the given format without saving anything. Converter warnings are returned as well.
+func ConvertPreview(h hyphae.ExistingHypha, to hyphae.TextFormat) (converted string, warnings []string, err error) {
+	if !h.HasTextFile() {
+		return "", nil, errors.New("ui.convert_no_text")
+	}
+	from := hyphae.DetectTextFormat(h.TextFilePath())
+	if from == to {
+		return "", nil, errors.New("ui.convert_same_format")
+	}
+	text, err := hyphae.FetchMycomarkupFile(h)
+	if err != nil {
+		return "", nil, err
+	}
+	return converter.ConvertFormat(text, from, to)
+}
+
+// ConvertFormat converts the text part of the hypha to the given format, changes the text file extension and makes a history record about that. The conversion is based on the given revision, see BaseRevision. If the text was changed since then, nothing is converted, so that the user never saves a conversion they have not previewed. An empty base revision skips this check.
+func ConvertFormat(u *user.User, h hyphae.ExistingHypha, to hyphae.TextFormat, baseRevision string) error {
+	if !u.CanProceed("convert") {
+		rejectConvertLog(h, u, "no rights")
+		return errors.New("ui.act_norights_convert")
+	}
+
+	// The operation is started before the text is read, so that nobody can change the text between the check and the conversion.
+	hop := history.
+		Operation(history.TypeConvertFormat).
+		WithMsg(fmt.Sprintf("Convert ‘%s’ to %s", h.CanonicalName(), hyphae.FormatName(to))).
+		WithUser(u)
+	var (
+		oldPath   = h.TextFilePath()
+		newPath   = strings.TrimSuffix(oldPath, filepath.Ext(oldPath)) + hyphae.FormatExtension(to)
+		oldFormat = hyphae.DetectTextFormat(oldPath)
+	)
+
+	if baseRevision != "" && !history.SameRevision(baseRevision, BaseRevision(h)) {
+		rejectConvertLog(h, u, "changed since the preview")
+		hop.Abort()
+		return errors.New("ui.convert_changed")
+	}
+
+	converted, _, err := ConvertPreview(h, to)
+	if err != nil {
+		rejectConvertLog(h, u, err.Error())
+		hop.Abort()
+		return err
+	}
+	oldText, err := hyphae.FetchMycomarkupFile(h)
+	if err != nil {
+		hop.Abort()
+		return err
+	}
+
+	hop.WithFilesRenamed(map[string]string{oldPath: newPath})
+	if hop.HasErrors() {
+		hop.Abort()
+		return hop.Errs[0]
+	}
+
+	// The hypha is only moved to the new file once the converted text is there.
+	if err := os.WriteFile(newPath, []byte(converted), 0666); err != nil {
+		hop.WithFilesRenamed(map[string]string{newPath: oldPath}).WithErrAbort(err)
+		return err
+	}
+	hop.WithFiles(newPath)
+	hyphae.RenameHyphaTo(h, h.CanonicalName(), func(path string) string {
+		if path == oldPath {
+			return newPath
+		}
+		return path
+	})
+	if hop.Apply().HasErrors() {
+		return hop.Errs[0]
+	}
+
+	backlinks.UpdateBacklinksAfterConvert(h, oldText, oldFormat)
+	search.UpdateAfterEdit(h)
+	return nil
+}
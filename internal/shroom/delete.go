@@ -2,11 +2,14 @@ package shroom
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/bouncepaw/mycorrhiza/history"
 	"github.com/bouncepaw/mycorrhiza/internal/backlinks"
 	"github.com/bouncepaw/mycorrhiza/internal/categories"
 	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/leases"
+	"github.com/bouncepaw/mycorrhiza/internal/search"
 	"github.com/bouncepaw/mycorrhiza/internal/user"
 )
 
@@ -17,6 +20,13 @@ func Delete(u *user.User, h hyphae.ExistingHypha) error {
 		WithMsg(fmt.Sprintf("Delete ‘%s’", h.CanonicalName())).
 		WithUser(u)
 
+	// Remember the categories to restore them with the hypha
+	cats := categories.CategoriesWithHypha(h.CanonicalName())
+	for _, cat := range cats {
+		hop.WithTrailer(history.CategoryTrailer, cat)
+	}
+	hop.WithTrailer(history.CategoryCountTrailer, strconv.Itoa(len(cats)))
+
 	originalText, _ := hyphae.FetchMycomarkupFile(h)
 	switch h := h.(type) {
 	case *hyphae.MediaHypha:
@@ -33,6 +43,8 @@ func Delete(u *user.User, h hyphae.ExistingHypha) error {
 	}
 	backlinks.UpdateBacklinksAfterDelete(h, originalText)
 	categories.RemoveHyphaFromAllCategories(h.CanonicalName())
+	search.UpdateAfterDelete(h.CanonicalName())
+	leases.ReleaseAll(h.CanonicalName())
 	hyphae.DeleteHypha(h)
 	return nil
 }
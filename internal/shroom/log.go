@@ -14,6 +14,13 @@ func rejectRenameLog(h hyphae.Hypha, u *user.User, errmsg string) {
 		"errmsg", errmsg)
 }
 
+func rejectConvertLog(h hyphae.Hypha, u *user.User, errmsg string) {
+	slog.Info("Reject convert",
+		"hyphaName", h.CanonicalName(),
+		"username", u.Name,
+		"errmsg", errmsg)
+}
+
 func rejectRemoveMediaLog(h hyphae.Hypha, u *user.User, errmsg string) {
 	slog.Info("Reject remove media",
 		"hyphaName", h.CanonicalName(),
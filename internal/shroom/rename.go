@@ -6,6 +6,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/bouncepaw/mycorrhiza/history"
@@ -14,12 +15,14 @@ import (
 	"github.com/bouncepaw/mycorrhiza/internal/cfg"
 	"github.com/bouncepaw/mycorrhiza/internal/files"
 	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/leases"
+	"github.com/bouncepaw/mycorrhiza/internal/search"
 	"github.com/bouncepaw/mycorrhiza/internal/user"
 	"github.com/bouncepaw/mycorrhiza/util"
 )
 
-// Rename renames the old hypha to the new name and makes a history record about that. Call if and only if the user has the permission to rename.
-func Rename(oldHypha hyphae.ExistingHypha, newName string, recursive bool, leaveRedirections bool, u *user.User) error {
+// Rename renames the old hypha to the new name and makes a history record about that. If updateLinks is true, local links to the renamed hyphae are rewritten in the same record. Call if and only if the user has the permission to rename.
+func Rename(oldHypha hyphae.ExistingHypha, newName string, recursive bool, leaveRedirections bool, updateLinks bool, u *user.User) error {
 	// * bouncepaw hates this function and related renaming functions
 	if newName == "" {
 		rejectRenameLog(oldHypha, u, "no new name given")
@@ -56,18 +59,21 @@ func Rename(oldHypha hyphae.ExistingHypha, newName string, recursive bool, leave
 		return err
 	}
 
-	hop := history.Operation(history.TypeRenameHypha).WithUser(u)
+	var (
+		renamedNames = make(map[string]string)
+		linkingNames []string
+	)
+	for _, h := range hyphaeToRename {
+		renamedNames[h.CanonicalName()] = re.ReplaceAllString(h.CanonicalName(), newName)
+	}
+	if updateLinks {
+		linkingNames = hyphaeLinkingTo(renamedNames)
+	}
 
+	hop := history.Operation(history.TypeRenameHypha).WithUser(u)
+	msg := fmt.Sprintf("Rename ‘%s’ to ‘%s’", oldHypha.CanonicalName(), newName)
 	if len(hyphaeToRename) > 0 {
-		hop.WithMsg(fmt.Sprintf(
-			"Rename ‘%s’ to ‘%s’ recursively",
-			oldHypha.CanonicalName(),
-			newName))
-	} else {
-		hop.WithMsg(fmt.Sprintf(
-			"Rename ‘%s’ to ‘%s’",
-			oldHypha.CanonicalName(),
-			newName))
+		msg += " recursively"
 	}
 
 	hop.WithFilesRenamed(renameMap)
@@ -79,10 +85,12 @@ func Rename(oldHypha hyphae.ExistingHypha, newName string, recursive bool, leave
 	for _, h := range hyphaeToRename {
 		var (
 			oldName = h.CanonicalName()
-			newName = re.ReplaceAllString(oldName, newName)
+			newName = renamedNames[oldName]
 		)
 		hyphae.RenameHyphaTo(h, newName, replaceName)
 		backlinks.UpdateBacklinksAfterRename(h, oldName)
+		search.UpdateAfterRename(h, oldName)
+		leases.ReleaseAll(oldName)
 		categories.RenameHyphaInAllCategories(oldName, newName)
 		if leaveRedirections {
 			if err := leaveRedirection(oldName, newName, hop); err != nil {
@@ -92,11 +100,72 @@ func Rename(oldHypha hyphae.ExistingHypha, newName string, recursive bool, leave
 		}
 	}
 
-	hop.Apply()
+	updatedCount := 0
+	for _, linkingName := range linkingNames {
+		updated, err := updateLinksIn(linkingName, renamedNames, hop)
+		if err != nil {
+			hop.WithErrAbort(err)
+			return err
+		}
+		if updated {
+			updatedCount++
+		}
+	}
+	if updatedCount > 0 {
+		msg += fmt.Sprintf(", updating links in %d hyphae", updatedCount)
+	}
+
+	hop.WithMsg(msg).Apply()
 
 	return nil
 }
 
+// hyphaeLinkingTo returns names of the hyphae that link to any of the hyphae being renamed. The map is from old names to new names.
+func hyphaeLinkingTo(renamedNames map[string]string) []string {
+	var (
+		seen   = make(map[string]bool)
+		result []string
+	)
+	for oldName := range renamedNames {
+		for _, linkingName := range backlinks.BacklinksFor(oldName) {
+			if !seen[linkingName] {
+				seen[linkingName] = true
+				result = append(result, linkingName)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// updateLinksIn rewrites links to the renamed hyphae in the hypha that was called linkingName before the renaming. It might have been renamed too.
+func updateLinksIn(linkingName string, renamedNames map[string]string, hop *history.Op) (updated bool, err error) {
+	currentName, renamed := renamedNames[linkingName]
+	if !renamed {
+		currentName = linkingName
+	}
+	h, ok := hyphae.ByName(currentName).(hyphae.ExistingHypha)
+	if !ok || !h.HasTextFile() {
+		return false, nil
+	}
+
+	oldText, err := hyphae.FetchMycomarkupFile(h)
+	if err != nil {
+		return false, err
+	}
+	format := hyphae.DetectTextFormat(h.TextFilePath())
+	newText := backlinks.RewriteLinks(linkingName, currentName, format, oldText, renamedNames)
+	if newText == oldText {
+		return false, nil
+	}
+	if err := writeTextToDisk(h, []byte(newText), hop); err != nil {
+		return false, err
+	}
+	backlinks.UpdateBacklinksAfterEdit(h, oldText)
+	search.UpdateAfterEdit(h)
+	return true, nil
+}
+
 const redirectionTemplate = `=> %[1]s | 👁️➡️ %[2]s
 <= %[1]s | full
 `
@@ -111,6 +180,7 @@ func leaveRedirection(oldName, newName string, hop *history.Op) error {
 		h := hyphae.ExtendEmptyToTextual(emptyHypha, filepath.Join(files.HyphaeDir(), oldName+".myco"))
 		hyphae.Insert(h)
 		categories.AddHyphaToCategory(oldName, cfg.RedirectionCategory)
+		defer search.UpdateAfterEdit(h)
 		defer backlinks.UpdateBacklinksAfterEdit(h, "")
 		return writeTextToDisk(h, []byte(text), hop)
 	default:
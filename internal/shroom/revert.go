@@ -0,0 +1,158 @@
+package shroom
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/bouncepaw/mycorrhiza/history"
+	"github.com/bouncepaw/mycorrhiza/internal/backlinks"
+	"github.com/bouncepaw/mycorrhiza/internal/categories"
+	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/mimetype"
+	"github.com/bouncepaw/mycorrhiza/internal/search"
+	"github.com/bouncepaw/mycorrhiza/internal/user"
+)
+
+// Revert restores the text and the media of the hypha as they were at the revision and makes a history record about that. Deleted hyphae are restored too.
+func Revert(u *user.User, hyphaName, revHash string) error {
+	return revert(u, hyphaName, revHash, fmt.Sprintf("Revert ‘%s’ to %s", hyphaName, revHash))
+}
+
+// Undelete restores the hypha deleted in the revision with everything it had before, including its categories. The categories of hyphae deleted by older versions are not known, see history.Deletion.
+func Undelete(u *user.User, hyphaName, deletionHash string) error {
+	if _, ok := hyphae.ByName(hyphaName).(*hyphae.EmptyHypha); !ok {
+		return errors.New("ui.undelete_exists")
+	}
+	err := revert(u, hyphaName, deletionHash+"~", fmt.Sprintf("Restore ‘%s’ deleted in %s", hyphaName, deletionHash))
+	if err != nil {
+		return err
+	}
+	cats, err := history.TrailerValues(deletionHash, history.CategoryTrailer)
+	if err != nil {
+		slog.Error("Failed to read categories of deleted hypha", "hyphaName", hyphaName, "revHash", deletionHash, "err", err)
+	}
+	if counts, _ := history.TrailerValues(deletionHash, history.CategoryCountTrailer); len(counts) == 0 {
+		slog.Warn("Categories of deleted hypha were not saved, restoring it without them", "hyphaName", hyphaName, "revHash", deletionHash)
+	}
+	for _, cat := range cats {
+		categories.AddHyphaToCategory(hyphaName, cat)
+	}
+	return nil
+}
+
+func revert(u *user.User, hyphaName, revHash, message string) error {
+	h := hyphae.ByName(hyphaName)
+	if !u.CanProceed("upload-text") {
+		rejectEditLog(h, u, "no rights")
+		return errors.New("ui.act_norights_edit")
+	}
+
+	paths, err := history.HyphaFilesAtRevision(hyphaName, revHash)
+	if err != nil {
+		return err
+	}
+	var oldTextPath, oldMediaPath string
+	for _, path := range paths {
+		if _, isText, _ := mimetype.DataFromFilename(path); isText {
+			oldTextPath = path
+		} else {
+			oldMediaPath = path
+		}
+	}
+	if oldTextPath == "" && oldMediaPath == "" {
+		return errors.New("ui.revert_no_hypha")
+	}
+
+	var (
+		textPath, mediaPath string
+		oldText, _          = hyphae.FetchMycomarkupFile(h)
+	)
+	switch h := h.(type) {
+	case *hyphae.TextualHypha:
+		textPath = h.TextFilePath()
+	case *hyphae.MediaHypha:
+		mediaPath = h.MediaFilePath()
+		if h.HasTextFile() {
+			textPath = h.TextFilePath()
+		}
+	}
+
+	hop := history.
+		Operation(history.TypeEditText).
+		WithMsg(message).
+		WithUser(u)
+
+	var removed, restored []string
+	// restore puts the file the hypha had at the revision in place of the current one.
+	restore := func(oldPath, currentPath string) error {
+		if currentPath != "" && currentPath != oldPath {
+			removed = append(removed, currentPath)
+		}
+		if oldPath == "" {
+			return nil
+		}
+		contents, err := history.FileAtRevision(oldPath, revHash)
+		if err != nil {
+			return err
+		}
+		if currentPath == oldPath {
+			if current, err := os.ReadFile(currentPath); err == nil && string(current) == contents {
+				return nil
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(oldPath), 0777); err != nil {
+			return err
+		}
+		if err := os.WriteFile(oldPath, []byte(contents), 0666); err != nil {
+			return err
+		}
+		restored = append(restored, oldPath)
+		return nil
+	}
+	if err := restore(oldTextPath, textPath); err != nil {
+		hop.WithErrAbort(err)
+		return err
+	}
+	if err := restore(oldMediaPath, mediaPath); err != nil {
+		hop.WithErrAbort(err)
+		return err
+	}
+
+	if len(removed) == 0 && len(restored) == 0 {
+		// Nothing changed since the revision
+		hop.Abort()
+		return nil
+	}
+	if len(removed) > 0 {
+		hop.WithFilesRemoved(removed...)
+	}
+	if len(restored) > 0 {
+		hop.WithFiles(restored...)
+	}
+	if hop.Apply().HasErrors() {
+		return hop.Errs[0]
+	}
+
+	if existing, ok := h.(hyphae.ExistingHypha); ok {
+		hyphae.DeleteHypha(existing)
+	}
+	var (
+		empty      = hyphae.ByName(hyphaName).(*hyphae.EmptyHypha)
+		revertedTo hyphae.ExistingHypha
+	)
+	switch {
+	case oldTextPath != "" && oldMediaPath != "":
+		revertedTo = hyphae.ExtendTextualToMedia(hyphae.ExtendEmptyToTextual(empty, oldTextPath), oldMediaPath)
+	case oldTextPath != "":
+		revertedTo = hyphae.ExtendEmptyToTextual(empty, oldTextPath)
+	default:
+		revertedTo = hyphae.ExtendEmptyToMedia(empty, oldMediaPath)
+	}
+	hyphae.Insert(revertedTo)
+	backlinks.UpdateBacklinksAfterEdit(revertedTo, oldText)
+	search.UpdateAfterEdit(revertedTo)
+	return nil
+}
@@ -1,29 +1,172 @@
 package shroom
 
 import (
+	"sort"
 	"strings"
 
 	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
 	"github.com/bouncepaw/mycorrhiza/util"
 )
 
-// YieldHyphaNamesContainingString picks hyphae with have a string in their title, sorts and iterates over them in alphabetical order.
-func YieldHyphaNamesContainingString(query string) <-chan string {
+// HyphaNamesMatching returns names of hyphae that match the query, the best matches first. Every word of the query has to match a word of the name, in any order, but small typos are tolerated. If the query is empty, all hyphae are returned in alphabetical order. If limit is positive, at most limit names are returned.
+func HyphaNamesMatching(query string, limit int) []string {
 	query = util.CanonicalName(strings.TrimSpace(query))
+	if query == "" {
+		var result []string
+		for hyphaName := range hyphae.PathographicSort(yieldExistingHyphaNames()) {
+			result = append(result, hyphaName)
+		}
+		return truncate(result, limit)
+	}
+
+	type match struct {
+		name  string
+		score float64
+	}
+	var (
+		queryWords = nameWords(query)
+		matches    []match
+	)
+	for h := range hyphae.YieldExistingHyphae() {
+		if score := hyphaNameScore(h.CanonicalName(), query, queryWords); score > 0 {
+			matches = append(matches, match{h.CanonicalName(), score})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		if len(matches[i].name) != len(matches[j].name) {
+			return len(matches[i].name) < len(matches[j].name)
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	result := make([]string, len(matches))
+	for i, m := range matches {
+		result[i] = m.name
+	}
+	return truncate(result, limit)
+}
+
+func yieldExistingHyphaNames() chan string {
 	out := make(chan string)
-	sorted := hyphae.PathographicSort(out)
 	go func() {
 		for h := range hyphae.YieldExistingHyphae() {
-			if hyphaNameMatchesString(h.CanonicalName(), query) {
-				out <- h.CanonicalName()
-			}
+			out <- h.CanonicalName()
 		}
 		close(out)
 	}()
-	return sorted
+	return out
+}
+
+func truncate(names []string, limit int) []string {
+	if limit > 0 && len(names) > limit {
+		return names[:limit]
+	}
+	return names
 }
 
-// This thing gotta be changed one day, when a hero has time to implement a good searching algorithm.
-func hyphaNameMatchesString(hyphaName, query string) bool {
-	return strings.Contains(hyphaName, query)
+// nameWords splits a canonical hypha name into words.
+func nameWords(name string) []string {
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == '/'
+	})
+}
+
+// hyphaNameScore tells how well the hypha name matches the query. Zero means it does not match at all.
+func hyphaNameScore(hyphaName, query string, queryWords []string) float64 {
+	var (
+		words = nameWords(hyphaName)
+		score float64
+	)
+	for _, queryWord := range queryWords {
+		var best float64
+		for _, word := range words {
+			best = max(best, wordScore(word, queryWord))
+		}
+		if best == 0 {
+			return 0
+		}
+		score += best
+	}
+	score /= float64(len(queryWords))
+
+	// Whole-name matches are the best, and so are the names that start like the query.
+	switch {
+	case hyphaName == query:
+		score += 2
+	case strings.HasPrefix(hyphaName, query):
+		score += 1
+	case strings.Contains(hyphaName, query):
+		score += 0.5
+	}
+	return score
+}
+
+// wordScore tells how well a word of a hypha name matches a word of the query. Zero means no match.
+func wordScore(word, queryWord string) float64 {
+	switch {
+	case word == queryWord:
+		return 1
+	case strings.HasPrefix(word, queryWord):
+		return 0.9
+	case strings.Contains(word, queryWord):
+		return 0.7
+	}
+
+	allowed := allowedTypos(queryWord)
+	if allowed == 0 {
+		return 0
+	}
+	if d := editDistance(word, queryWord); d <= allowed {
+		return 0.6 - 0.1*float64(d)
+	}
+	// The query word might be the beginning of the word with a typo in it.
+	if wordRunes := []rune(word); len(wordRunes) > len([]rune(queryWord)) {
+		if d := editDistance(string(wordRunes[:len([]rune(queryWord))]), queryWord); d <= allowed {
+			return 0.5 - 0.1*float64(d)
+		}
+	}
+	return 0
+}
+
+// allowedTypos returns how many typos are tolerated in the query word. Short words have to be typed correctly, otherwise too much matches.
+func allowedTypos(queryWord string) int {
+	switch n := len([]rune(queryWord)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance is the Damerau–Levenshtein distance between the strings, in its optimal string alignment variant: a swap of two adjacent runes counts as one typo.
+func editDistance(a, b string) int {
+	var (
+		ra, rb = []rune(a), []rune(b)
+		prev2  = make([]int, len(rb)+1)
+		prev   = make([]int, len(rb)+1)
+		curr   = make([]int, len(rb)+1)
+	)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(rb)]
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/bouncepaw/mycorrhiza/history"
 	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/search"
 	"github.com/bouncepaw/mycorrhiza/internal/user"
 )
 
@@ -26,6 +27,7 @@ func RemoveMedia(u *user.User, h *hyphae.MediaHypha) error {
 	if h.HasTextFile() {
 		hyphae.Insert(hyphae.ShrinkMediaToTextual(h))
 	} else {
+		search.UpdateAfterDelete(h.CanonicalName())
 		hyphae.DeleteHypha(h)
 	}
 	return nil
@@ -16,6 +16,7 @@ import (
 	"github.com/bouncepaw/mycorrhiza/internal/files"
 	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
 	"github.com/bouncepaw/mycorrhiza/internal/mimetype"
+	"github.com/bouncepaw/mycorrhiza/internal/search"
 	"github.com/bouncepaw/mycorrhiza/internal/user"
 )
 
@@ -43,8 +44,8 @@ func writeTextToDisk(h hyphae.ExistingHypha, data []byte, hop *history.Op) error
 	return nil
 }
 
-// UploadText edits the hypha's text part and makes a history record about that.
-func UploadText(h hyphae.Hypha, data []byte, userMessage string, u *user.User, format hyphae.TextFormat) error {
+// UploadText edits the hypha's text part and makes a history record about that. The edit is based on the given revision, see BaseRevision. If someone else changed the text since then, an *EditConflictError is returned and nothing is saved. An empty base revision skips this check.
+func UploadText(h hyphae.Hypha, data []byte, userMessage string, u *user.User, format hyphae.TextFormat, baseRevision string) error {
 	hop := history.
 		Operation(history.TypeEditText).
 		WithMsg(historyMessageForTextUpload(h, userMessage)).
@@ -76,6 +77,13 @@ func UploadText(h hyphae.Hypha, data []byte, userMessage string, u *user.User, f
 		return nil
 	}
 
+	// Someone else might have changed the text while the user was editing it
+	if err := checkEditConflict(h, baseRevision, oldText, data); err != nil {
+		rejectEditLog(h, u, "edit conflict")
+		hop.Abort()
+		return err
+	}
+
 	// At this point, we have a savable user-generated Mycomarkup document. Gotta save it.
 
 	switch h := h.(type) {
@@ -93,6 +101,7 @@ func UploadText(h hyphae.Hypha, data []byte, userMessage string, u *user.User, f
 
 		hyphae.Insert(H)
 		backlinks.UpdateBacklinksAfterEdit(H, "")
+		search.UpdateAfterEdit(H)
 	case *hyphae.MediaHypha:
 		// TODO: that []byte(...) part should be removed
 		if bytes.Equal(data, []byte(oldText)) {
@@ -108,6 +117,7 @@ func UploadText(h hyphae.Hypha, data []byte, userMessage string, u *user.User, f
 		}
 
 		backlinks.UpdateBacklinksAfterEdit(h, oldText)
+		search.UpdateAfterEdit(h)
 	case *hyphae.TextualHypha:
 		oldText, err := hyphae.FetchMycomarkupFile(h)
 		if err != nil {
@@ -129,6 +139,7 @@ func UploadText(h hyphae.Hypha, data []byte, userMessage string, u *user.User, f
 		}
 
 		backlinks.UpdateBacklinksAfterEdit(h, oldText)
+		search.UpdateAfterEdit(h)
 	}
 
 	hop.Apply()
@@ -207,6 +218,8 @@ func UploadBinary(h hyphae.Hypha, mime string, file multipart.File, u *user.User
 		}
 	}
 
+	search.UpdateAfterEdit(hyphae.ByName(h.CanonicalName()))
+
 	history.
 		Operation(history.TypeEditBinary).
 		WithMsg(historyMessageForMediaUpload(h, mime)).
@@ -0,0 +1,246 @@
+package user
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bouncepaw/mycorrhiza/internal/files"
+	"github.com/bouncepaw/mycorrhiza/util"
+)
+
+// APIToken is a personal access token for scripts and other non-interactive clients. Only the hash of the token is stored, the token itself is shown once, when it is created.
+type APIToken struct {
+	// ID identifies the token on the settings page. It is not secret.
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	// Name is what the user called the token, so they remember what it is for.
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+	// Scopes limit what the token can do. No scopes mean all rights of the user.
+	Scopes    []string  `json:"scopes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// ExpiresAt is zero for tokens that never expire.
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// The prefix makes the tokens easy to recognize, for example, by secret scanners.
+const apiTokenPrefix = "myco_"
+
+// Scope — Group with the most rights the scope allows
+var scopeGroups = map[string]string{
+	"read":   "reader",
+	"edit":   "trusted",
+	"delete": "moderator",
+	"admin":  "admin",
+}
+
+// ValidScope checks whether the API token scope exists.
+func ValidScope(scope string) bool {
+	_, ok := scopeGroups[scope]
+	return ok
+}
+
+var (
+	apiTokens      []*APIToken
+	apiTokensMutex sync.RWMutex
+)
+
+// Expired is true if the token cannot be used anymore.
+func (t *APIToken) Expired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt)
+}
+
+// NewAPIToken creates a token for the user and saves it. The token itself is returned, it cannot be seen later. A zero lifetime means the token never expires.
+func NewAPIToken(username, name string, scopes []string, lifetime time.Duration) (string, error) {
+	for _, scope := range scopes {
+		if !ValidScope(scope) {
+			return "", fmt.Errorf("invalid scope ‘%s’", scope)
+		}
+	}
+	if !HasUsername(username) {
+		return "", ErrUnknownUsername
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("token name must not be empty")
+	}
+
+	id, err := util.RandomString(4)
+	if err != nil {
+		return "", err
+	}
+	secret, err := util.RandomString(20)
+	if err != nil {
+		return "", err
+	}
+	token := apiTokenPrefix + secret
+
+	t := &APIToken{
+		ID:        id,
+		Username:  username,
+		Name:      name,
+		Hash:      hashAPIToken(token),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	if lifetime > 0 {
+		t.ExpiresAt = t.CreatedAt.Add(lifetime)
+	}
+
+	apiTokensMutex.Lock()
+	defer apiTokensMutex.Unlock()
+	apiTokens = append(apiTokens, t)
+	if err := dumpAPITokens(); err != nil {
+		apiTokens = apiTokens[:len(apiTokens)-1]
+		return "", err
+	}
+	slog.Info("Created API token", "username", username, "id", id)
+	return token, nil
+}
+
+// RevokeAPIToken deletes the token of the user with the given ID.
+func RevokeAPIToken(username, id string) error {
+	apiTokensMutex.Lock()
+	defer apiTokensMutex.Unlock()
+	i := slices.IndexFunc(apiTokens, func(t *APIToken) bool {
+		return t.ID == id && t.Username == username
+	})
+	if i < 0 {
+		return fmt.Errorf("no API token ‘%s’", id)
+	}
+	revoked := apiTokens[i]
+	apiTokens = slices.Delete(apiTokens, i, i+1)
+	if err := dumpAPITokens(); err != nil {
+		apiTokens = slices.Insert(apiTokens, i, revoked)
+		return err
+	}
+	slog.Info("Revoked API token", "username", username, "id", id)
+	return nil
+}
+
+// revokeAPITokensOf deletes all tokens of the user, so that a new user with the same name does not get them. If they cannot be saved, the tokens still do not work until the wiki restarts.
+func revokeAPITokensOf(username string) error {
+	apiTokensMutex.Lock()
+	defer apiTokensMutex.Unlock()
+	apiTokens = slices.DeleteFunc(apiTokens, func(t *APIToken) bool {
+		return t.Username == username
+	})
+	if err := dumpAPITokens(); err != nil {
+		slog.Error("Failed to revoke API tokens of deleted user", "username", username, "err", err)
+		return err
+	}
+	return nil
+}
+
+// APITokensOf returns the tokens of the user, the newest first.
+func APITokensOf(username string) []APIToken {
+	apiTokensMutex.RLock()
+	defer apiTokensMutex.RUnlock()
+	var result []APIToken
+	for _, t := range apiTokens {
+		if t.Username == username {
+			result = append(result, *t)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// ByAPIToken finds the user the token belongs to. The user's rights are limited by the token's scopes. If the token is unknown or expired, an anon user is returned.
+func ByAPIToken(token string) *User {
+	hash := hashAPIToken(token)
+	apiTokensMutex.RLock()
+	defer apiTokensMutex.RUnlock()
+	for _, t := range apiTokens {
+		if subtle.ConstantTimeCompare([]byte(t.Hash), []byte(hash)) == 1 {
+			if t.Expired() {
+				return EmptyUser()
+			}
+			return ByName(t.Username).limitedTo(t.Scopes)
+		}
+	}
+	return EmptyUser()
+}
+
+// limitedTo returns the user with rights no more than the scopes allow.
+func (user *User) limitedTo(scopes []string) *User {
+	user.RLock()
+	defer user.RUnlock()
+	if len(scopes) == 0 || user.Group == "anon" {
+		return user
+	}
+	maxRight := 0
+	for _, scope := range scopes {
+		maxRight = max(maxRight, groupRight[scopeGroups[scope]])
+	}
+	if groupRight[user.Group] <= maxRight {
+		return user
+	}
+	// The scope groups are the groups with the most rights at their levels.
+	group := "reader"
+	for _, scopeGroup := range scopeGroups {
+		if groupRight[scopeGroup] == maxRight {
+			group = scopeGroup
+		}
+	}
+	return &User{
+		Name:         user.Name,
+		Group:        group,
+		RegisteredAt: user.RegisteredAt,
+		Source:       user.Source,
+	}
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func readAPITokens() {
+	contents, err := os.ReadFile(files.APITokensJSON())
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to read api-tokens.json", "err", err)
+		os.Exit(1)
+	}
+
+	var tmp []*APIToken
+	if err = json.Unmarshal(contents, &tmp); err != nil {
+		slog.Error("Failed to unmarshal api-tokens.json contents", "err", err)
+		os.Exit(1)
+	}
+
+	apiTokensMutex.Lock()
+	apiTokens = tmp
+	apiTokensMutex.Unlock()
+	slog.Info("Indexed API tokens", "n", len(tmp))
+}
+
+// dumpAPITokens saves the tokens. Lock the tokens before calling.
+func dumpAPITokens() error {
+	blob, err := json.MarshalIndent(apiTokens, "", "\t")
+	if err != nil {
+		slog.Error("Failed to marshal api-tokens.json", "err", err)
+		return err
+	}
+	if err = os.WriteFile(files.APITokensJSON(), blob, 0600); err != nil {
+		slog.Error("Failed to write api-tokens.json", "err", err)
+		return err
+	}
+	return nil
+}
@@ -22,6 +22,7 @@ func ReadUsersFromFilesystem() {
 	if cfg.UseAuth {
 		rememberUsers(usersFromFile())
 		readTokensToUsers()
+		readAPITokens()
 	}
 }
 
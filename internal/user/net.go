@@ -23,8 +23,11 @@ func CanProceed(rq *http.Request, route string) bool {
 	return FromRequest(rq).CanProceed(route)
 }
 
-// FromRequest returns user from `rq`. If there is no user, an anon user is returned instead.
+// FromRequest returns user from `rq`. The user is identified by an API token in the Authorization header or by the session cookie. If there is no user, an anon user is returned instead.
 func FromRequest(rq *http.Request) *User {
+	if token, ok := strings.CutPrefix(rq.Header.Get("Authorization"), "Bearer "); ok {
+		return ByAPIToken(strings.TrimSpace(token))
+	}
 	cookie, err := rq.Cookie("mycorrhiza_token")
 	if err != nil {
 		return EmptyUser()
@@ -37,6 +37,7 @@ var minimalRights = map[string]int{
 	"edit":                 1,
 	"upload-binary":        1,
 	"rename":               1,
+	"convert":              1,
 	"upload-text":          1,
 	"add-to-category":      1,
 	"remove-from-category": 1,
@@ -1,6 +1,7 @@
 package user
 
 import (
+	"errors"
 	"sort"
 	"sync"
 )
@@ -85,10 +86,12 @@ func DeleteUser(name string) error {
 	user, loaded := users.LoadAndDelete(name)
 	if loaded {
 		u := user.(*User)
+		u.Lock()
 		u.Name = "anon"
 		u.Group = "anon"
 		u.Password = ""
-		return SaveUserDatabase()
+		u.Unlock()
+		return errors.Join(revokeAPITokensOf(name), SaveUserDatabase())
 	}
 	return nil
 }
@@ -2,12 +2,14 @@
 package misc
 
 import (
+	"encoding/json"
 	"io"
 	"log/slog"
 	"math/rand"
 	"mime"
 	"net/http"
 	"path/filepath"
+	"strconv"
 
 	"github.com/gorilla/mux"
 
@@ -15,6 +17,7 @@ import (
 	"github.com/bouncepaw/mycorrhiza/internal/cfg"
 	"github.com/bouncepaw/mycorrhiza/internal/files"
 	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/search"
 	"github.com/bouncepaw/mycorrhiza/internal/shroom"
 	"github.com/bouncepaw/mycorrhiza/internal/user"
 	"github.com/bouncepaw/mycorrhiza/l18n"
@@ -40,6 +43,8 @@ func InitHandlers(rtr *mux.Router) {
 	rtr.HandleFunc("/random", handlerRandom)
 	rtr.HandleFunc("/about", handlerAbout)
 	rtr.HandleFunc("/title-search/", handlerTitleSearch)
+	rtr.HandleFunc("/search", handlerSearch)
+	rtr.HandleFunc("/autocomplete", handlerAutocomplete)
 	initViews()
 }
 
@@ -80,6 +85,7 @@ func handlerReindex(w http.ResponseWriter, rq *http.Request) {
 	slog.Info("Reindexing hyphae", "hyphaeDir", files.HyphaeDir())
 	hyphae.Index(files.HyphaeDir())
 	backlinks.IndexBacklinks()
+	search.IndexHyphae()
 	http.Redirect(w, rq, "/", http.StatusSeeOther)
 }
 
@@ -176,11 +182,50 @@ func handlerTitleSearch(w http.ResponseWriter, rq *http.Request) {
 		query       = rq.FormValue("q")
 		hyphaName   = util.CanonicalName(query)
 		_, nameFree = hyphae.AreFreeNames(hyphaName)
-		results     []string
+		results     = shroom.HyphaNamesMatching(query, 0)
 	)
-	for hyphaName := range shroom.YieldHyphaNamesContainingString(query) {
-		results = append(results, hyphaName)
-	}
 	w.WriteHeader(http.StatusOK)
 	viewTitleSearch(viewutil.MetaFrom(w, rq), query, hyphaName, !nameFree, results)
 }
+
+// autocompleteSuggestion is an element of the JSON array handlerAutocomplete returns.
+type autocompleteSuggestion struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+}
+
+// handlerAutocomplete returns the names of hyphae that match the q parameter best, as JSON. The limit parameter caps their number, 10 by default.
+func handlerAutocomplete(w http.ResponseWriter, rq *http.Request) {
+	util.PrepareRq(rq)
+	limit, err := strconv.Atoi(rq.FormValue("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 10
+	}
+	suggestions := []autocompleteSuggestion{}
+	for _, hyphaName := range shroom.HyphaNamesMatching(rq.FormValue("q"), limit) {
+		suggestions = append(suggestions, autocompleteSuggestion{
+			Name:  hyphaName,
+			Title: util.BeautifulName(hyphaName),
+		})
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(suggestions); err != nil {
+		slog.Error("Failed to write autocomplete suggestions", "err", err)
+	}
+}
+
+// handlerSearch shows hyphae that match the search query. See search.ParseQuery for the syntax.
+func handlerSearch(w http.ResponseWriter, rq *http.Request) {
+	util.PrepareRq(rq)
+	var (
+		query        = rq.FormValue("q")
+		results, err = search.Search(query)
+	)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		viewSearch(viewutil.MetaFrom(w, rq), query, nil, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	viewSearch(viewutil.MetaFrom(w, rq), query, results, "")
+}
@@ -4,19 +4,26 @@ import (
 	"embed"
 
 	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/search"
 	"github.com/bouncepaw/mycorrhiza/web/viewutil"
 )
 
 var (
 	//go:embed *html
-	fs                          embed.FS
-	chainList, chainTitleSearch viewutil.Chain
-	ruTranslation               = `
+	fs                                       embed.FS
+	chainList, chainTitleSearch, chainSearch viewutil.Chain
+	ruTranslation                            = `
 {{define "list of hyphae"}}Список гиф{{end}}
 {{define "search:"}}Поиск: {{.}}{{end}}
 {{define "search results for"}}Результаты поиска для «{{.}}»{{end}}
 {{define "search no results"}}Ничего не найдено.{{end}}
 {{define "x total"}}{{.}} всего.{{end}}
+{{define "search in texts"}}<a href="/search?q={{.}}">Искать в текстах гиф</a>.{{end}}
+{{define "full-text search"}}Полнотекстовый поиск{{end}}
+{{define "search"}}Искать{{end}}
+{{define "search query error"}}Ошибка в запросе: {{.}}.{{end}}
+{{define "search syntax"}}Кроме слов и «фраз в кавычках», можно писать фильтры: <code>category:</code>, <code>prefix:</code>, <code>format:md</code>, <code>author:</code>, <code>changed:&gt;2026-01-01</code>, <code>sort:name</code>. Слово с минусом исключает гифы с ним. <a href="/help/en/search">Справка на английском.</a>{{end}}
+{{define "changed at"}}Изменена {{.}}{{end}}
 {{define "go to hypha"}}Перейти к гифе <a class="wikilink{{if .HasExactMatch | not}} wikilink_new{{end}}" href="/hypha/{{.MatchedHyphaName}}">{{beautifulName .MatchedHyphaName}}</a>.{{end}}
 `
 )
@@ -24,6 +31,7 @@ var (
 func initViews() {
 	chainList = viewutil.CopyEnRuWith(fs, "view_list.html", ruTranslation)
 	chainTitleSearch = viewutil.CopyEnRuWith(fs, "view_title_search.html", ruTranslation)
+	chainSearch = viewutil.CopyEnRuWith(fs, "view_search.html", ruTranslation)
 }
 
 type listDatum struct {
@@ -62,3 +70,19 @@ func viewTitleSearch(meta viewutil.Meta, query string, hyphaName string, hasExac
 		HasExactMatch:    hasExactMatch,
 	})
 }
+
+type searchData struct {
+	*viewutil.BaseData
+	Query   string
+	Results []search.Result
+	Error   string
+}
+
+func viewSearch(meta viewutil.Meta, query string, results []search.Result, errMsg string) {
+	viewutil.ExecutePage(meta, chainSearch, searchData{
+		BaseData: &viewutil.BaseData{},
+		Query:    query,
+		Results:  results,
+		Error:    errMsg,
+	})
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"regexp"
+	"sort"
+)
+
+// TextEdit is a replacement of the source bytes From:To with Text.
+type TextEdit struct {
+	From, To int
+	Text     string
+}
+
+// SubmatchEdits returns edits that replace the first submatch of every match of re outside the skipped ranges. Spaces around the submatch are kept.
+func SubmatchEdits(source []byte, re *regexp.Regexp, skip [][2]int, rewrite func(string) (string, bool)) (edits []TextEdit) {
+	for _, match := range re.FindAllSubmatchIndex(source, -1) {
+		from, to := match[2], match[3]
+		for from < to && (source[from] == ' ' || source[from] == '\t') {
+			from++
+		}
+		for to > from && (source[to-1] == ' ' || source[to-1] == '\t') {
+			to--
+		}
+		if from == to || inRanges(from, skip) {
+			continue
+		}
+		if replacement, ok := rewrite(string(source[from:to])); ok {
+			edits = append(edits, TextEdit{from, to, replacement})
+		}
+	}
+	return edits
+}
+
+// ApplyTextEdits applies the edits to the source. Edits that overlap with previous ones are ignored.
+func ApplyTextEdits(source []byte, edits []TextEdit) []byte {
+	if len(edits) == 0 {
+		return source
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].From < edits[j].From
+	})
+	var (
+		result []byte
+		last   int
+	)
+	for _, edit := range edits {
+		if edit.From < last {
+			continue
+		}
+		result = append(result, source[last:edit.From]...)
+		result = append(result, edit.Text...)
+		last = edit.To
+	}
+	return append(result, source[last:]...)
+}
+
+func inRanges(pos int, ranges [][2]int) bool {
+	for _, r := range ranges {
+		if r[0] <= pos && pos < r[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -9,6 +9,7 @@ import (
 	"sort"
 
 	"github.com/bouncepaw/mycorrhiza/internal/cfg"
+	"github.com/bouncepaw/mycorrhiza/internal/leases"
 	"github.com/bouncepaw/mycorrhiza/internal/user"
 	"github.com/bouncepaw/mycorrhiza/util"
 	"github.com/bouncepaw/mycorrhiza/web/viewutil"
@@ -26,6 +27,8 @@ const adminTranslationRu = `
 {{define "panel shutdown"}}Выключить вики{{end}}
 {{define "panel reindex hyphae"}}Переиндексировать гифы{{end}}
 {{define "panel interwiki"}}Интервики{{end}}
+{{define "panel leases"}}Правки в процессе{{end}}
+{{define "panel deleted"}}Удалённые гифы{{end}}
 
 {{define "manage users"}}Управление пользователями{{end}}
 {{define "create user"}}Создать пользователя{{end}}
@@ -144,6 +147,26 @@ func handlerAdminUsers(w http.ResponseWriter, rq *http.Request) {
 	viewList(viewutil.MetaFrom(w, rq), users)
 }
 
+// handlerAdminLeases lists the edit leases.
+func handlerAdminLeases(w http.ResponseWriter, rq *http.Request) {
+	_ = pageAdminLeases.RenderTo(viewutil.MetaFrom(w, rq), map[string]any{
+		"Leases": leases.All(),
+	})
+}
+
+// handlerAdminLeaseBreak breaks the edit lease.
+func handlerAdminLeaseBreak(w http.ResponseWriter, rq *http.Request) {
+	var (
+		hyphaName = rq.PostFormValue("hypha")
+		username  = rq.PostFormValue("username")
+		id        = rq.PostFormValue("id")
+	)
+	if leases.Release(hyphaName, username, id) {
+		slog.Info("An admin broke an edit lease", "hyphaName", hyphaName, "username", username)
+	}
+	http.Redirect(w, rq, "/admin/leases", http.StatusSeeOther)
+}
+
 func handlerAdminUserEdit(w http.ResponseWriter, rq *http.Request) {
 	vars := mux.Vars(rq)
 	u := user.ByName(vars["username"])
@@ -159,9 +182,14 @@ func handlerAdminUserEdit(w http.ResponseWriter, rq *http.Request) {
 		newGroup := f.Get("group")
 
 		if user.ValidGroup(newGroup) {
+			// Requests with the user's API tokens might be reading the group now.
+			u.Lock()
 			u.Group = newGroup
+			u.Unlock()
 			if err := user.SaveUserDatabase(); err != nil {
+				u.Lock()
 				u.Group = oldGroup
+				u.Unlock()
 				slog.Info("Failed to save user database", "err", err)
 				f = f.WithError(err)
 			} else {
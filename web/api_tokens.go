@@ -0,0 +1,76 @@
+package web
+
+import (
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bouncepaw/mycorrhiza/internal/user"
+	"github.com/bouncepaw/mycorrhiza/util"
+	"github.com/bouncepaw/mycorrhiza/web/viewutil"
+
+	"github.com/gorilla/mux"
+)
+
+// sessionUser returns the user logged in with the session cookie. API tokens are not accepted here, so that a token with few scopes cannot create a token with more.
+func sessionUser(rq *http.Request) *user.User {
+	if rq.Header.Get("Authorization") != "" {
+		return user.EmptyUser()
+	}
+	return user.FromRequest(rq)
+}
+
+// handlerAPITokens lists the user's API tokens (GET) or creates a new one (POST).
+func handlerAPITokens(w http.ResponseWriter, rq *http.Request) {
+	u := sessionUser(rq)
+	if u.Group == "anon" {
+		util.HTTP404Page(w, "404 page not found")
+		return
+	}
+
+	var (
+		f        = util.NewFormData()
+		newToken string
+	)
+	if rq.Method == http.MethodPost {
+		f = util.FormDataFromRequest(rq, []string{"name", "expiry"})
+		days, err := strconv.Atoi(f.Get("expiry"))
+		if err != nil || days < 0 {
+			days = 0
+		}
+		newToken, err = user.NewAPIToken(u.Name, f.Get("name"), rq.PostForm["scope"], time.Duration(days)*24*time.Hour)
+		if err != nil {
+			slog.Info("Failed to create API token", "username", u.Name, "err", err)
+			f = f.WithError(err)
+		} else {
+			f = util.NewFormData()
+		}
+	}
+
+	w.Header().Set("Content-Type", mime.TypeByExtension(".html"))
+	if f.HasError() {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	_ = pageAPITokens.RenderTo(viewutil.MetaFrom(w, rq), map[string]any{
+		"Form":     f,
+		"Tokens":   user.APITokensOf(u.Name),
+		"NewToken": newToken,
+	})
+}
+
+// handlerAPITokenRevoke revokes the user's API token.
+func handlerAPITokenRevoke(w http.ResponseWriter, rq *http.Request) {
+	u := sessionUser(rq)
+	if u.Group == "anon" {
+		util.HTTP404Page(w, "404 page not found")
+		return
+	}
+	if err := user.RevokeAPIToken(u.Name, mux.Vars(rq)["id"]); err != nil {
+		slog.Info("Failed to revoke API token", "username", u.Name, "err", err)
+		util.HTTP404Page(w, "404 page not found")
+		return
+	}
+	http.Redirect(w, rq, "/settings/api-tokens", http.StatusSeeOther)
+}
@@ -1,11 +1,15 @@
 package web
 
 import (
+	"errors"
 	"log/slog"
+	"mime"
 	"net/http"
+	"strings"
 
 	"github.com/bouncepaw/mycorrhiza/hypview"
 	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/leases"
 	"github.com/bouncepaw/mycorrhiza/internal/renderer"
 	"github.com/bouncepaw/mycorrhiza/internal/shroom"
 	"github.com/bouncepaw/mycorrhiza/internal/user"
@@ -20,7 +24,10 @@ func initMutators(r *mux.Router) {
 	r.PathPrefix("/edit/").HandlerFunc(handlerEdit)
 	r.PathPrefix("/rename/").HandlerFunc(handlerRename).Methods("GET", "POST")
 	r.PathPrefix("/delete/").HandlerFunc(handlerDelete).Methods("GET", "POST")
+	r.PathPrefix("/convert/").HandlerFunc(handlerConvert).Methods("GET", "POST")
 	r.PathPrefix("/remove-media/").HandlerFunc(handlerRemoveMedia).Methods("POST")
+	r.PathPrefix("/revert/").HandlerFunc(handlerRevert).Methods("POST")
+	r.PathPrefix("/undelete/").HandlerFunc(handlerUndelete).Methods("POST")
 	r.PathPrefix("/upload-binary/").HandlerFunc(handlerUploadBinary)
 	r.PathPrefix("/upload-text/").HandlerFunc(handlerUploadText)
 }
@@ -121,6 +128,7 @@ func handlerRename(w http.ResponseWriter, rq *http.Request) {
 		newName           = util.CanonicalName(rq.PostFormValue("new-name"))
 		recursive         = rq.PostFormValue("recursive") == "true"
 		leaveRedirections = rq.PostFormValue("redirection") == "true"
+		updateLinks       = rq.PostFormValue("update-links") == "true"
 	)
 
 	if rq.Method == "GET" {
@@ -128,7 +136,7 @@ func handlerRename(w http.ResponseWriter, rq *http.Request) {
 		return
 	}
 
-	if err := shroom.Rename(oldHypha, newName, recursive, leaveRedirections, u); err != nil {
+	if err := shroom.Rename(oldHypha, newName, recursive, leaveRedirections, updateLinks, u); err != nil {
 		slog.Error("Failed to rename hypha",
 			"err", err, "username", u.Name, "hyphaName", oldHypha.CanonicalName())
 		viewutil.HttpErr(meta, http.StatusForbidden, oldHypha.CanonicalName(), lc.Get(err.Error())) // TODO: localize
@@ -137,6 +145,127 @@ func handlerRename(w http.ResponseWriter, rq *http.Request) {
 	http.Redirect(w, rq, "/hypha/"+newName, http.StatusSeeOther)
 }
 
+func handlerConvert(w http.ResponseWriter, rq *http.Request) {
+	util.PrepareRq(rq)
+	var (
+		u    = user.FromRequest(rq)
+		lc   = l18n.FromRequest(rq)
+		h    = hyphae.ByName(util.HyphaNameFromRq(rq, "convert"))
+		meta = viewutil.MetaFrom(w, rq)
+	)
+
+	switch h.(type) {
+	case *hyphae.EmptyHypha:
+		slog.Info("Trying to convert empty hypha",
+			"username", u.Name, "hyphaName", h.CanonicalName())
+		viewutil.HttpErr(meta, http.StatusForbidden, h.CanonicalName(), "Cannot convert an empty hypha") // TODO: localize
+		return
+	}
+
+	if !u.CanProceed("convert") {
+		slog.Info("No rights to convert hypha",
+			"username", u.Name, "hyphaName", h.CanonicalName())
+		viewutil.HttpErr(meta, http.StatusForbidden, h.CanonicalName(), lc.Get("ui.act_norights_convert"))
+		return
+	}
+
+	var (
+		existing   = h.(hyphae.ExistingHypha)
+		fromFormat = hyphae.DetectTextFormat(existing.TextFilePath())
+		toFormat   = hyphae.FormatMarkdown
+	)
+	if fromFormat == hyphae.FormatMarkdown {
+		toFormat = hyphae.FormatMycomarkup
+	}
+
+	// changed is true if the hypha was changed since the preview the user confirmed.
+	showPreview := func(changed bool) {
+		baseRevision := shroom.BaseRevision(existing)
+		converted, warnings, err := shroom.ConvertPreview(existing, toFormat)
+		if err != nil {
+			viewutil.HttpErr(meta, http.StatusForbidden, h.CanonicalName(), lc.Get(err.Error()))
+			return
+		}
+		preview, _ := renderer.RenderForPreview(converted, toFormat, h.CanonicalName())
+		_ = pageHyphaConvert.RenderTo(meta, map[string]any{
+			"HyphaName":    h.CanonicalName(),
+			"FromFormat":   hyphae.FormatName(fromFormat),
+			"ToFormat":     hyphae.FormatName(toFormat),
+			"Converted":    converted,
+			"Warnings":     warnings,
+			"Preview":      preview,
+			"BaseRevision": baseRevision,
+			"Changed":      changed,
+		})
+	}
+
+	if rq.Method == "GET" {
+		showPreview(false)
+		return
+	}
+
+	err := shroom.ConvertFormat(u, existing, toFormat, rq.PostFormValue("base-revision"))
+	if err != nil && err.Error() == "ui.convert_changed" {
+		showPreview(true)
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to convert hypha",
+			"err", err, "username", u.Name, "hyphaName", h.CanonicalName())
+		viewutil.HttpErr(meta, http.StatusForbidden, h.CanonicalName(), lc.Get(err.Error()))
+		return
+	}
+	http.Redirect(w, rq, "/hypha/"+h.CanonicalName(), http.StatusSeeOther)
+}
+
+// handlerRevert restores the hypha as it was at the revision. The address looks like /revert/{hash}/{hypha}.
+func handlerRevert(w http.ResponseWriter, rq *http.Request) {
+	util.PrepareRq(rq)
+	var (
+		u    = user.FromRequest(rq)
+		lc   = l18n.FromRequest(rq)
+		meta = viewutil.MetaFrom(w, rq)
+
+		revHash, slug, found = strings.Cut(strings.TrimPrefix(rq.URL.Path, "/revert/"), "/")
+		hyphaName            = util.CanonicalName(slug)
+	)
+	if !found || !util.IsRevHash(revHash) || len(slug) < 1 {
+		http.Error(w, "400 bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := shroom.Revert(u, hyphaName, revHash); err != nil {
+		slog.Info("Failed to revert hypha", "hyphaName", hyphaName, "revHash", revHash, "err", err)
+		viewutil.HttpErr(meta, http.StatusForbidden, hyphaName, lc.Get(err.Error()))
+		return
+	}
+	http.Redirect(w, rq, "/hypha/"+hyphaName, http.StatusSeeOther)
+}
+
+// handlerUndelete restores the hypha deleted in the revision. The address looks like /undelete/{hash}/{hypha}.
+func handlerUndelete(w http.ResponseWriter, rq *http.Request) {
+	util.PrepareRq(rq)
+	var (
+		u    = user.FromRequest(rq)
+		lc   = l18n.FromRequest(rq)
+		meta = viewutil.MetaFrom(w, rq)
+
+		revHash, slug, found = strings.Cut(strings.TrimPrefix(rq.URL.Path, "/undelete/"), "/")
+		hyphaName            = util.CanonicalName(slug)
+	)
+	if !found || !util.IsRevHash(revHash) || len(slug) < 1 {
+		http.Error(w, "400 bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := shroom.Undelete(u, hyphaName, revHash); err != nil {
+		slog.Info("Failed to undelete hypha", "hyphaName", hyphaName, "revHash", revHash, "err", err)
+		viewutil.HttpErr(meta, http.StatusForbidden, hyphaName, lc.Get(err.Error()))
+		return
+	}
+	http.Redirect(w, rq, "/hypha/"+hyphaName, http.StatusSeeOther)
+}
+
 // handlerEdit shows the edit form. It doesn't edit anything actually.
 func handlerEdit(w http.ResponseWriter, rq *http.Request) {
 	util.PrepareRq(rq)
@@ -158,6 +287,8 @@ func handlerEdit(w http.ResponseWriter, rq *http.Request) {
 		return
 	}
 
+	lease, others := leases.Take(h.CanonicalName(), u.Name, "")
+
 	var isMarkdown bool
 	switch h.(type) {
 	case *hyphae.EmptyHypha:
@@ -178,12 +309,15 @@ func handlerEdit(w http.ResponseWriter, rq *http.Request) {
 	_ = pageHyphaEdit.RenderTo(
 		viewutil.MetaFrom(w, rq),
 		map[string]any{
-			"HyphaName":  hyphaName,
-			"Content":    content,
-			"IsNew":      isNew,
-			"IsMarkdown": isMarkdown,
-			"Message":    "",
-			"Preview":    "",
+			"HyphaName":    hyphaName,
+			"Content":      content,
+			"IsNew":        isNew,
+			"IsMarkdown":   isMarkdown,
+			"Message":      "",
+			"Preview":      "",
+			"BaseRevision": shroom.BaseRevision(h),
+			"Leases":       others,
+			"LeaseID":      lease.ID,
 		})
 }
 
@@ -198,9 +332,11 @@ func handlerUploadText(w http.ResponseWriter, rq *http.Request) {
 		h         = hyphae.ByName(hyphaName)
 		_, isNew  = h.(*hyphae.EmptyHypha)
 
-		textData = rq.PostFormValue("text")
-		action   = rq.PostFormValue("action")
-		message  = rq.PostFormValue("message")
+		textData     = rq.PostFormValue("text")
+		action       = rq.PostFormValue("action")
+		message      = rq.PostFormValue("message")
+		baseRevision = rq.PostFormValue("base-revision")
+		leaseID      = rq.PostFormValue("lease")
 	)
 
 	// Determine format
@@ -225,26 +361,45 @@ func handlerUploadText(w http.ResponseWriter, rq *http.Request) {
 	}
 
 	if action == "preview" {
+		lease, others := leases.Take(h.CanonicalName(), u.Name, leaseID)
 		preview, _ := renderer.RenderForPreview(textData, format, hyphaName)
 		isMarkdown := (format == hyphae.FormatMarkdown)
 
 		_ = pageHyphaEdit.RenderTo(
 			viewutil.MetaFrom(w, rq),
 			map[string]any{
-				"HyphaName":  hyphaName,
-				"Content":    textData,
-				"IsNew":      isNew,
-				"IsMarkdown": isMarkdown,
-				"Message":    message,
-				"Preview":    preview,
+				"HyphaName":    hyphaName,
+				"Content":      textData,
+				"IsNew":        isNew,
+				"IsMarkdown":   isMarkdown,
+				"Message":      message,
+				"Preview":      preview,
+				"BaseRevision": baseRevision,
+				"Leases":       others,
+				"LeaseID":      lease.ID,
 			})
 		return
 	}
 
-	if err := shroom.UploadText(h, []byte(textData), message, u, format); err != nil {
+	err := shroom.UploadText(h, []byte(textData), message, u, format, baseRevision)
+	var conflict *shroom.EditConflictError
+	if errors.As(err, &conflict) {
+		w.Header().Set("Content-Type", mime.TypeByExtension(".html"))
+		w.WriteHeader(http.StatusConflict)
+		_ = pageHyphaConflict.RenderTo(
+			viewutil.MetaFrom(w, rq),
+			map[string]any{
+				"HyphaName": hyphaName,
+				"Conflict":  conflict,
+				"Message":   message,
+			})
+		return
+	}
+	if err != nil {
 		viewutil.HttpErr(meta, http.StatusForbidden, hyphaName, err.Error())
 		return
 	}
+	leases.Release(h.CanonicalName(), u.Name, leaseID)
 	http.Redirect(w, rq, "/hypha/"+hyphaName, http.StatusSeeOther)
 }
 
@@ -10,10 +10,11 @@ import (
 //go:embed views/*.html
 var fs embed.FS
 
-var pageOrphans, pageBacklinks, pageUserList, pageChangePassword *newtmpl.Page
-var pageHyphaDelete, pageHyphaEdit, pageHyphaEmpty, pageHypha *newtmpl.Page
+var pageOrphans, pageDeleted, pageBacklinks, pageUserList, pageChangePassword, pageAPITokens *newtmpl.Page
+var pageHyphaDelete, pageHyphaConvert, pageHyphaEdit, pageHyphaConflict, pageHyphaEmpty, pageHypha *newtmpl.Page
 var pageRevision, pageMedia *newtmpl.Page
 var pageAuthLock, pageAuthLogin, pageAuthLogout, pageAuthRegister *newtmpl.Page
+var pageAdminLeases *newtmpl.Page
 var pageCatPage, pageCatList, pageCatEdit *newtmpl.Page
 
 var panelChain, listChain, newUserChain, editUserChain, deleteUserChain viewutil.Chain
@@ -30,6 +31,17 @@ func initPages() {
 		"orphaned hyphae":    "Гифы-сироты",
 		"orphan description": "Ниже перечислены гифы без ссылок на них.",
 	}, "views/orphans.html")
+	pageDeleted = newtmpl.NewPage(fs, map[string]string{
+		"deleted hyphae":      "Удалённые гифы",
+		"deleted description": "Ниже перечислены гифы, которые были удалены и не созданы заново. При восстановлении гифе вернутся её текст, медиа и категории, какими они были до удаления. Старые версии Микоризы не сохраняли категории удалённых гиф, такие гифы восстанавливаются без них.",
+		"categories unknown":  "Её категории не были сохранены при удалении. Добавьте их заново после восстановления.",
+		"hypha":               "Гифа",
+		"deleted by":          "Кто удалил",
+		"deleted at":          "Когда",
+		"actions":             "Действия",
+		"restore":             "Восстановить",
+		"no deleted hyphae":   "Удалённых гиф нет.",
+	}, "views/deleted.html")
 	pageBacklinks = newtmpl.NewPage(fs, map[string]string{
 		"backlinks to text": `Обратные ссылки на {{.}}`,
 		"backlinks to link": `Обратные ссылки на <a href="/hypha/{{.}}">{{beautifulName .}}</a>`,
@@ -50,12 +62,59 @@ func initPages() {
 		"password":                  "Пароль",
 		"submit":                    "Поменять",
 	}, "views/change-password.html")
+	pageAPITokens = newtmpl.NewPage(fs, map[string]string{
+		"api tokens":     "API-токены",
+		"api tokens tip": `С этими токенами скрипты и боты могут пользоваться <a href="/help/en/api">API</a> от вашего имени. Передавайте токен в заголовке <code>Authorization: Bearer …</code>.`,
+		"new token tip":  "Вот ваш новый токен. Скопируйте его сейчас, больше вы его не увидите.",
+		"token name":     "Название",
+		"scopes":         "Области",
+		"created at":     "Создан",
+		"expires at":     "Истекает",
+		"actions":        "Действия",
+		"all rights":     "все права",
+		"never":          "Никогда",
+		"revoke":         "Отозвать",
+		"no tokens":      "У вас нет API-токенов.",
+		"new token":      "Новый токен",
+		"scopes tip":     "Токен не может больше, чем вы. Без областей он может всё, что можете вы.",
+		"scope read":     "read — читать гифы",
+		"scope edit":     "edit — также править и переименовывать гифы и их медиа",
+		"scope delete":   "delete — также удалять гифы",
+		"scope admin":    "admin — также администрировать вики",
+		"expiry":         "Истекает через",
+		"7 days":         "7 дней",
+		"30 days":        "30 дней",
+		"90 days":        "90 дней",
+		"1 year":         "1 год",
+		"create token":   "Создать токен",
+	}, "views/api-tokens.html")
+	pageAdminLeases = newtmpl.NewPage(fs, map[string]string{
+		"edit leases":     "Правки в процессе",
+		"edit leases tip": "Когда кто-то открывает редактор, остальные видят, что гифу редактируют. Эта отметка снимается, когда текст сохранён, или через 15 минут. Снимите отметку, если она уже неверна.",
+		"hypha":           "Гифа",
+		"user":            "Пользователь",
+		"since":           "С",
+		"until":           "До",
+		"actions":         "Действия",
+		"break":           "Снять",
+		"no leases":       "Сейчас никто ничего не редактирует.",
+	}, "views/admin-leases.html")
 	pageHyphaDelete = newtmpl.NewPage(fs, map[string]string{
 		"delete hypha?":     "Удалить {{beautifulName .}}?",
 		"delete [[hypha]]?": "Удалить <a href=\"/hypha/{{.}}\">{{beautifulName .}}</a>?",
 		"want to delete?":   "Вы действительно хотите удалить эту гифу?",
 		"delete tip":        "Нельзя отменить удаление гифы, но её история останется доступной.",
 	}, "views/hypha-delete.html")
+	pageHyphaConvert = newtmpl.NewPage(fs, map[string]string{
+		"convert hypha?":      "Сменить формат {{beautifulName .HyphaName}} на {{.ToFormat}}?",
+		"convert [[hypha]]?":  `Сменить формат <a href="/hypha/{{.HyphaName}}">{{beautifulName .HyphaName}}</a> на {{.ToFormat}}?`,
+		"convert changed":     "Гифа изменилась, пока была открыта эта страница. Ниже преобразование нового текста, проверьте его ещё раз.",
+		"convert tip":         "Текст будет преобразован из {{.FromFormat}} в {{.ToFormat}}. Часть разметки может не пережить преобразование, поэтому проверьте результат ниже перед подтверждением.",
+		"convert warnings":    "Предупреждения преобразователя:",
+		"convert no warnings": "Преобразователь не выдал предупреждений.",
+		"converted text":      "Преобразованный текст",
+		"converted preview":   "Предпросмотр",
+	}, "views/hypha-convert.html")
 	pageHyphaEdit = newtmpl.NewPage(fs, map[string]string{
 		"editing hypha":               `Редактирование {{beautifulName .}}`,
 		"editing [[hypha]]":           `Редактирование <a href="/hypha/{{.}}">{{beautifulName .}}</a>`,
@@ -92,15 +151,31 @@ func initPages() {
 		"current date utc":   "Дата UTC",
 		"current time utc":   "Время UTC",
 		"selflink":           `Ссылка на вас`,
+		"being edited by":    `Редактирует <a href="/hypha/{{template "user hypha"}}/{{.Username}}">{{beautifulName .Username}}</a> с {{.Since.UTC.Format "2006-01-02 15:04"}} UTC`,
 	}, "views/hypha-edit.html")
+	pageHyphaConflict = newtmpl.NewPage(fs, map[string]string{
+		"conflict in hypha":     `Конфликт правок в {{beautifulName .}}`,
+		"conflict in [[hypha]]": `Конфликт правок в <a href="/hypha/{{.}}">{{beautifulName .}}</a>`,
+		"conflict tip":          `Кто-то изменил гифу, пока вы её редактировали, начиная с ревизии <a href="/rev/{{.BaseRevision}}/{{.HyphaName}}">{{.BaseRevision}}</a>. Ваша правка не сохранена. Проверьте объединённый текст ниже и сохраните его снова.`,
+		"conflict count":        `Некоторые изменения не удалось объединить: {{.}}. Они отмечены <code>&lt;&lt;&lt;&lt;&lt;&lt;&lt;</code>, <code>=======</code> и <code>&gt;&gt;&gt;&gt;&gt;&gt;&gt;</code>. Оставьте один из вариантов и удалите отметки.`,
+		"merged text":           `Объединённый текст`,
+		"describe your changes": `Опишите ваши правки`,
+		"save":                  `Сохранить`,
+		"discard":               `Отменить вашу правку`,
+		"current version":       `Текущая версия, ревизия <a href="/rev/{{.CurrentRevision}}/{{.HyphaName}}">{{.CurrentRevision}}</a>`,
+		"your version":          `Ваша версия`,
+	}, "views/hypha-conflict.html")
 	pageHypha = newtmpl.NewPage(fs, map[string]string{
 		"edit text":     "Редактировать",
 		"log out":       "Выйти",
 		"admin panel":   "Админка",
+		"api tokens":    "API-токены",
 		"subhyphae":     "Подгифы",
 		"history":       "История",
+		"blame":         "Авторство",
 		"rename":        "Переименовать",
 		"delete":        "Удалить",
+		"convert":       "Сменить формат",
 		"view markup":   "Посмотреть разметку",
 		"manage media":  "Медиа",
 		"turn to media": "Превратить в медиа-гифу",
@@ -117,10 +192,13 @@ func initPages() {
 		"upload a media":                   `Загрузить медиа`,
 		"upload a media tip":               `Загрузите изображение, видео или аудио. Распространённые форматы можно просматривать из браузера, остальные можно только скачать и просмотреть локально. Позже вы можете дописать пояснение к этому медиа.`,
 		"upload a media btn":               `Загрузить`,
+
+		"being edited by": `Редактирует <a href="/hypha/{{template "user hypha"}}/{{.Username}}">{{beautifulName .Username}}</a> с {{.Since.UTC.Format "2006-01-02 15:04"}} UTC`,
 	}, "views/hypha.html")
 	pageRevision = newtmpl.NewPage(fs, map[string]string{
 		"revision warning": "Обратите внимание, просмотр медиа в истории пока что недоступен.",
 		"revision link":    "Посмотреть Микоразметку для этой ревизии",
+		"restore revision": "Восстановить эту ревизию",
 		"hypha at rev":     "{{.HyphaName}} на {{.RevHash}}",
 	}, "views/hypha-revision.html")
 	pageMedia = newtmpl.NewPage(fs, map[string]string{ // TODO: сделать новый перевод
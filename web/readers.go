@@ -19,6 +19,8 @@ import (
 	"github.com/bouncepaw/mycorrhiza/internal/cfg"
 	"github.com/bouncepaw/mycorrhiza/internal/files"
 	"github.com/bouncepaw/mycorrhiza/internal/hyphae"
+	"github.com/bouncepaw/mycorrhiza/internal/leases"
+	"github.com/bouncepaw/mycorrhiza/internal/mdrenderer"
 	"github.com/bouncepaw/mycorrhiza/internal/mimetype"
 	"github.com/bouncepaw/mycorrhiza/internal/renderer"
 	"github.com/bouncepaw/mycorrhiza/internal/tree"
@@ -48,6 +50,7 @@ func initReaders(r *mux.Router) {
 	// Backlinks
 	r.PathPrefix("/backlinks/").HandlerFunc(handlerBacklinks)
 	r.PathPrefix("/orphans").HandlerFunc(handlerOrphans)
+	r.HandleFunc("/deleted", handlerDeleted)
 }
 
 func handlerEditToday(w http.ResponseWriter, rq *http.Request) {
@@ -171,6 +174,13 @@ func handlerRevision(w http.ResponseWriter, rq *http.Request) {
 		mycoFilePath = h.TextFilePath()
 	case *hyphae.EmptyHypha:
 		mycoFilePath = filepath.Join(files.HyphaeDir(), h.CanonicalName()+".myco")
+		// The hypha might have been deleted since then, look for its text at the revision
+		paths, _ := history.HyphaFilesAtRevision(h.CanonicalName(), revHash)
+		for _, path := range paths {
+			if _, isText, _ := mimetype.DataFromFilename(path); isText {
+				mycoFilePath = path
+			}
+		}
 	}
 	textContents, err = history.FileAtRevision(mycoFilePath, revHash)
 	if err == nil {
@@ -242,6 +252,7 @@ func handlerHypha(w http.ResponseWriter, rq *http.Request) {
 			"GivenPermissionToModify": user.CanProceed(rq, "edit"),
 			"Categories":              cats,
 			"IsMediaHypha":            false,
+			"Leases":                  leases.OthersOf(h.CanonicalName(), meta.U.Name, ""),
 		}
 	)
 	slog.Info("reading hypha", "name", h.CanonicalName(), "can edit", data["GivenPermissionToModify"])
@@ -260,10 +271,9 @@ func handlerHypha(w http.ResponseWriter, rq *http.Request) {
 			// Detect format and render accordingly
 			format := hyphae.DetectTextFormat(h.TextFilePath())
 			if format == hyphae.FormatMarkdown {
-				// For Markdown, use simple rendering (OpenGraph can be improved later)
+				openGraph = template.HTML(mdrenderer.OpenGraph(hyphaName, fileContentsT))
 				contents, _ = renderer.RenderHyphaContent(h, string(fileContentsT), hyphaName)
 			} else {
-				// For Mycomarkup, keep existing OpenGraph handling
 				ctx, _ := mycocontext.ContextFromStringInput(string(fileContentsT), mycoopts.MarkupOptions(hyphaName))
 				getOpenGraph, descVisitor, imgVisitor := tools.OpenGraphVisitors(ctx)
 				ast := mycomarkup.BlockTree(ctx, descVisitor, imgVisitor)
@@ -298,6 +308,32 @@ func handlerBacklinks(w http.ResponseWriter, rq *http.Request) {
 		})
 }
 
+// handlerDeleted lists the deleted hyphae that can be restored.
+func handlerDeleted(w http.ResponseWriter, rq *http.Request) {
+	deletions, err := history.Deletions()
+	if err != nil {
+		slog.Error("Failed to find deleted hyphae", "err", err)
+	}
+	var (
+		seen    = map[string]bool{}
+		deleted []history.Deletion
+	)
+	for _, deletion := range deletions {
+		if seen[deletion.HyphaName] {
+			continue
+		}
+		seen[deletion.HyphaName] = true
+		if _, isEmpty := hyphae.ByName(deletion.HyphaName).(*hyphae.EmptyHypha); isEmpty {
+			deleted = append(deleted, deletion)
+		}
+	}
+	_ = pageDeleted.RenderTo(viewutil.MetaFrom(w, rq),
+		map[string]any{
+			"Addr":      "/deleted",
+			"Deletions": deleted,
+		})
+}
+
 func handlerOrphans(w http.ResponseWriter, rq *http.Request) {
 	_ = pageOrphans.RenderTo(viewutil.MetaFrom(w, rq),
 		map[string]any{
@@ -0,0 +1,126 @@
+// Hypha name suggestions for the search bar and the editor. They come from /autocomplete.
+const autocomplete = {
+    delay: 150,
+
+    // fetcher returns a function that calls the callback with suggestions for the query, but only for the latest query and not too often.
+    fetcher() {
+        let timeout, latest = 0
+        return (query, callback) => {
+            clearTimeout(timeout)
+            timeout = setTimeout(() => {
+                const id = ++latest
+                fetch(`/autocomplete?q=${encodeURIComponent(query)}`)
+                    .then(response => response.json())
+                    .then(suggestions => {
+                        if (id === latest) callback(suggestions)
+                    })
+                    .catch(() => {})
+            }, autocomplete.delay)
+        }
+    },
+}
+
+// Search bar: the suggestions are shown in a datalist. Choosing one opens the hypha.
+;(() => {
+    const input = $('.top-bar__search-bar')
+    if (!input) return
+
+    const datalist = document.createElement('datalist')
+    datalist.id = 'top-bar__suggestions'
+    input.after(datalist)
+    input.setAttribute('list', datalist.id)
+    input.setAttribute('autocomplete', 'off')
+
+    const fetchSuggestions = autocomplete.fetcher()
+    let namesByTitle = {}
+    input.addEventListener('input', event => {
+        const chosen = namesByTitle[input.value]
+        if (chosen && (!(event instanceof InputEvent) || event.inputType === 'insertReplacementText')) {
+            window.location.href = `/hypha/${chosen}`
+            return
+        }
+        if (input.value.trim() === '') {
+            datalist.replaceChildren()
+            return
+        }
+        fetchSuggestions(input.value, suggestions => {
+            namesByTitle = {}
+            datalist.replaceChildren(...suggestions.map(({name, title}) => {
+                namesByTitle[title] = name
+                const option = document.createElement('option')
+                option.value = title
+                return option
+            }))
+        })
+    })
+})()
+
+// Editor: when the caret is in an unfinished [[link, the suggestions are shown under the textarea. Click one or press Tab to take the first one.
+;(() => {
+    const textarea = $('.edit-form__textarea')
+    if (!textarea) return
+
+    const list = document.createElement('ul')
+    list.className = 'edit-form__suggestions'
+    list.hidden = true
+    textarea.after(list)
+
+    const fetchSuggestions = autocomplete.fetcher()
+    let suggestions = []
+
+    // unfinishedLink returns the position of the link target start and the target typed so far, if the caret is in a link.
+    const unfinishedLink = () => {
+        if (textarea.selectionStart !== textarea.selectionEnd) return null
+        const before = textarea.value.slice(0, textarea.selectionStart)
+        const match = before.match(/\[\[([^\[\]|\n]*)$/)
+        return match ? {start: before.length - match[1].length, typed: match[1]} : null
+    }
+
+    const hide = () => {
+        list.hidden = true
+        suggestions = []
+    }
+
+    const insert = name => {
+        const link = unfinishedLink()
+        if (!link) return hide()
+        const after = textarea.value.slice(textarea.selectionStart)
+        const closing = /^(\]\]|\|)/.test(after) ? '' : ']]'
+        textarea.setRangeText(name + closing, link.start, textarea.selectionStart, 'end')
+        window.hyphaChanged = true
+        hide()
+        textarea.focus()
+    }
+
+    const update = () => {
+        const link = unfinishedLink()
+        if (!link || link.typed.trim() === '') return hide()
+        fetchSuggestions(link.typed, found => {
+            suggestions = found
+            list.replaceChildren(...found.map(({name, title}) => {
+                const item = document.createElement('li')
+                item.className = 'edit-form__suggestion'
+                item.textContent = title
+                item.addEventListener('mousedown', event => {
+                    event.preventDefault() // Keep the focus in the textarea.
+                    insert(name)
+                })
+                return item
+            }))
+            list.hidden = found.length === 0
+        })
+    }
+
+    textarea.addEventListener('input', update)
+    textarea.addEventListener('click', update)
+    textarea.addEventListener('blur', hide)
+    textarea.addEventListener('keydown', event => {
+        if (list.hidden) return
+        if (event.key === 'Tab' && suggestions.length > 0) {
+            event.preventDefault()
+            insert(suggestions[0].name)
+        } else if (event.key === 'Escape') {
+            hide()
+        }
+    })
+})()
@@ -11,6 +11,7 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/bouncepaw/mycorrhiza/api"
 	"github.com/bouncepaw/mycorrhiza/help"
 	"github.com/bouncepaw/mycorrhiza/history/histweb"
 	"github.com/bouncepaw/mycorrhiza/hypview"
@@ -42,6 +43,9 @@ func Handler() http.Handler {
 	// Public routes. They're always accessible regardless of the user status.
 	misc.InitAssetHandlers(router)
 
+	// The API checks the lock by itself.
+	api.InitHandlers(router)
+
 	// Auth
 	router.HandleFunc("/user-list", handlerUserList)
 	router.HandleFunc("/lock", handlerLock)
@@ -95,6 +99,8 @@ func Handler() http.Handler {
 		adminRouter.HandleFunc("/users/{username}/change-password", handlerAdminUserChangePassword).Methods(http.MethodPost)
 		adminRouter.HandleFunc("/users/{username}/delete", handlerAdminUserDelete).Methods(http.MethodGet, http.MethodPost)
 		adminRouter.HandleFunc("/users", handlerAdminUsers)
+		adminRouter.HandleFunc("/leases", handlerAdminLeases).Methods(http.MethodGet)
+		adminRouter.HandleFunc("/leases/break", handlerAdminLeaseBreak).Methods(http.MethodPost)
 
 		adminRouter.HandleFunc("/", handlerAdmin)
 
@@ -102,6 +108,8 @@ func Handler() http.Handler {
 		// TODO: check if necessary?
 		//settingsRouter.Use(groupMiddleware("settings"))
 		settingsRouter.HandleFunc("/change-password", handlerUserChangePassword).Methods(http.MethodGet, http.MethodPost)
+		settingsRouter.HandleFunc("/api-tokens", handlerAPITokens).Methods(http.MethodGet, http.MethodPost)
+		settingsRouter.HandleFunc("/api-tokens/{id}/revoke", handlerAPITokenRevoke).Methods(http.MethodPost)
 	}
 
 	// Index page